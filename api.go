@@ -0,0 +1,50 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+)
+
+// API is the feieyun open API implemented by *Client.
+// Depend on it instead of *Client to stub the gateway in unit tests, see package feiemock.
+type API interface {
+	// OpenPrinterAddList 批量添加打印机。
+	OpenPrinterAddList(ctx context.Context, req *PrinterAddReq) (*PrinterAddResp, error)
+	// OpenPrinterDelList 删除批量打印机。
+	OpenPrinterDelList(ctx context.Context, req *PrinterDelReq) (*PrinterDelResp, error)
+	// OpenPrintMsg 打印订单。
+	OpenPrintMsg(ctx context.Context, req *PrintMsgReq) (*PrintMsgResp, error)
+	// OpenPrintLabelMsg 标签机打印订单。
+	OpenPrintLabelMsg(ctx context.Context, req *PrintLabelMsgReq) (*PrintLabelMsgResp, error)
+	// OpenPrinterEdit 修改打印机信息。
+	OpenPrinterEdit(ctx context.Context, req *PrinterEditReq) (*PrinterEditResp, error)
+	// OpenDelPrinterSQS 清空待打印队列。
+	OpenDelPrinterSQS(ctx context.Context, req *DelPrinterSQSReq) (*DelPrinterSQSResp, error)
+	// OpenQueryOrderState 查询订单是否打印成功。
+	OpenQueryOrderState(ctx context.Context, req *QueryOrderStateReq) (*QueryOrderStateResp, error)
+	// OpenQueryOrderInfoByDate 查询指定打印机某天的订单统计数。
+	OpenQueryOrderInfoByDate(ctx context.Context, req *QueryOrderInfoByDateReq) (*QueryOrderInfoByDateResp, error)
+	// OpenQueryPrinterStatus 查询打印机状态。
+	OpenQueryPrinterStatus(ctx context.Context, req *QueryPrinterStatusReq) (*QueryPrinterStatusResp, error)
+	// AsyncPrinterResult 验证订单状态回调。
+	AsyncPrinterResult(ctx context.Context, req *AsyncPrinterResultReq) (*AsyncPrinterResultResp, error)
+}
+
+var _ API = (*Client)(nil)
@@ -0,0 +1,138 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// snListSep joins the SNs of Open_printerDelList.
+const snListSep = "-"
+
+// AddPrinters adds any number of printers, splitting them into chunks of MaxPrinterAddList
+// that run with the concurrency set by WithBatchConcurrency.
+// The results are merged per SN in the order of specs. A chunk that fails marks its printers
+// as failed and is reported in a *BatchError, the remaining chunks keep running.
+func (c *Client) AddPrinters(ctx context.Context, specs []PrinterSpec) ([]*PrinterResult, error) {
+	if len(specs) == 0 {
+		return nil, ErrEmptyPrinters
+	}
+	for _, spec := range specs {
+		if err := spec.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	results := make([]*PrinterResult, len(specs))
+	err := c.runChunks(ctx, len(specs), func(ctx context.Context, start, end int) error {
+		chunk := specs[start:end]
+		resp, err := c.OpenPrinterAddList(ctx, &PrinterAddReq{Printers: chunk})
+		if err == nil && resp.Ret != 0 {
+			err = &APIError{API: printerAddList, Ret: resp.Ret, Msg: resp.Msg}
+		}
+		if err != nil {
+			for i, spec := range chunk {
+				results[start+i] = &PrinterResult{PrinterSpec: spec, Reason: err.Error()}
+			}
+			return err
+		}
+		returned := make(map[string]*PrinterResult, len(chunk))
+		for _, result := range resp.Data.AddResults() {
+			returned[result.SN] = result
+		}
+		for i, spec := range chunk {
+			result := &PrinterResult{PrinterSpec: spec, Reason: "no result returned"}
+			if r, ok := returned[strings.TrimSpace(spec.SN)]; ok {
+				result.OK, result.Reason = r.OK, r.Reason
+			}
+			results[start+i] = result
+		}
+		return nil
+	})
+	return results, err
+}
+
+// DeletePrinters deletes any number of printers, splitting them into chunks of MaxPrinterAddList
+// that run with the concurrency set by WithBatchConcurrency.
+// The results are merged per SN in the order of sns. A chunk that fails marks its printers
+// as failed and is reported in a *BatchError, the remaining chunks keep running.
+func (c *Client) DeletePrinters(ctx context.Context, sns []string) ([]*PrinterResult, error) {
+	if len(sns) == 0 {
+		return nil, ErrEmptyPrinters
+	}
+	for _, sn := range sns {
+		if strings.TrimSpace(sn) == "" || strings.Contains(sn, snListSep) {
+			return nil, fmt.Errorf("feie: invalid printer sn %q", sn)
+		}
+	}
+	results := make([]*PrinterResult, len(sns))
+	err := c.runChunks(ctx, len(sns), func(ctx context.Context, start, end int) error {
+		chunk := sns[start:end]
+		resp, err := c.OpenPrinterDelList(ctx, &PrinterDelReq{SNList: strings.Join(chunk, snListSep)})
+		if err == nil && resp.Ret != 0 {
+			err = &APIError{API: printerDelList, Ret: resp.Ret, Msg: resp.Msg}
+		}
+		if err != nil {
+			for i, sn := range chunk {
+				results[start+i] = &PrinterResult{PrinterSpec: PrinterSpec{SN: sn}, Reason: err.Error()}
+			}
+			return err
+		}
+		copy(results[start:end], resp.Data.DelResults(chunk))
+		return nil
+	})
+	return results, err
+}
+
+// runChunks calls fn for every chunk of at most MaxPrinterAddList items with bounded concurrency.
+// The chunks that are not started because ctx is done are passed to fn with the canceled ctx.
+func (c *Client) runChunks(ctx context.Context, total int, fn func(ctx context.Context, start, end int) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, c.op.BatchConcurrency)
+	)
+	for start := 0; start < total; start += MaxPrinterAddList {
+		end := start + MaxPrinterAddList
+		if end > total {
+			end = total
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(start, end int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(ctx, start, end); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("printers %d-%d: %w", start, end-1, err))
+				mu.Unlock()
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return &BatchError{Errors: errs}
+	}
+	return nil
+}
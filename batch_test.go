@@ -0,0 +1,191 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestGateway starts a gateway answering every request with the value returned by fn.
+func newTestGateway(t *testing.T, fn func(form map[string]string) interface{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		form := make(map[string]string, len(r.MultipartForm.Value))
+		for k, v := range r.MultipartForm.Value {
+			form[k] = v[0]
+		}
+		if form[SigField] != sha1Sign(form[UserField], "ukey", form[SysTimeField]) {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ret": -2, "msg": "签名错误"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(fn(form))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_AddPrinters(t *testing.T) {
+	var calls int32
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		atomic.AddInt32(&calls, 1)
+		lines := strings.Split(form[PrinterContentField], "\n")
+		if strings.HasPrefix(lines[0], "sn200#") {
+			return map[string]interface{}{"ret": -2, "msg": "参数错误"}
+		}
+		data := map[string][]string{"ok": {}, "no": {}}
+		for _, line := range lines {
+			if strings.HasPrefix(line, "sn7#") {
+				data["no"] = append(data["no"], line+"  （错误：识别码不正确）")
+				continue
+			}
+			data["ok"] = append(data["ok"], line)
+		}
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": data}
+	})
+	c := New(context.Background(), WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL), WithBatchConcurrency(2))
+
+	specs := make([]PrinterSpec, 250)
+	for i := range specs {
+		specs[i] = PrinterSpec{SN: "sn" + strconv.Itoa(i), Key: "key"}
+	}
+	results, err := c.AddPrinters(context.Background(), specs)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 1 {
+		t.Fatalf("AddPrinters() error = %v, want one failed chunk", err)
+	}
+	if calls != 3 {
+		t.Errorf("AddPrinters() calls = %d, want 3", calls)
+	}
+	if len(results) != len(specs) {
+		t.Fatalf("AddPrinters() results = %d, want %d", len(results), len(specs))
+	}
+	for i, result := range results {
+		wantOK := i != 7 && i < 200
+		if result.SN != specs[i].SN || result.OK != wantOK {
+			t.Errorf("AddPrinters() result %d = %+v, want ok %v", i, result, wantOK)
+		}
+	}
+	if results[7].Reason != "识别码不正确" {
+		t.Errorf("AddPrinters() reason = %q", results[7].Reason)
+	}
+}
+
+func TestClient_DeletePrinters(t *testing.T) {
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		data := map[string][]string{"ok": {}, "no": {}}
+		for _, sn := range strings.Split(form[SNListField], "-") {
+			if sn == "sn1" {
+				data["no"] = append(data["no"], sn+"用户UID不匹配")
+				continue
+			}
+			data["ok"] = append(data["ok"], sn+"成功")
+		}
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": data}
+	})
+	c := New(context.Background(), WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+
+	results, err := c.DeletePrinters(context.Background(), []string{"sn1", "sn10", "sn2"})
+	if err != nil {
+		t.Fatalf("DeletePrinters() error = %v", err)
+	}
+	want := []struct {
+		ok     bool
+		reason string
+	}{{false, "用户UID不匹配"}, {true, ""}, {true, ""}}
+	for i, result := range results {
+		if result.OK != want[i].ok || result.Reason != want[i].reason {
+			t.Errorf("DeletePrinters() result %d = %+v, want %+v", i, result, want[i])
+		}
+	}
+	if _, err = c.DeletePrinters(context.Background(), []string{"sn1-sn2"}); err == nil {
+		t.Errorf("DeletePrinters() with '-' in sn want error")
+	}
+}
+
+func TestBatchError_IsAs(t *testing.T) {
+	apiErr := &APIError{API: printerAddList, Ret: 1002, Msg: "打印机编号错误"}
+	err := error(&BatchError{Errors: []error{context.DeadlineExceeded, fmt.Errorf("chunk 2: %w", apiErr)}})
+	tests := []struct {
+		name   string
+		target error
+		want   bool
+	}{
+		{"deadline", context.DeadlineExceeded, true},
+		{"canceled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(err, tt.target); got != tt.want {
+				t.Errorf("errors.Is = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	var target *APIError
+	if !errors.As(err, &target) || target != apiErr {
+		t.Errorf("errors.As = %v, want %v", target, apiErr)
+	}
+	if !(&BatchError{Errors: []error{apiErr}}).As(&target) {
+		t.Error("As did not find the APIError")
+	}
+}
+
+func TestClient_RequestUser(t *testing.T) {
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[UserField]}
+	})
+	c := New(context.Background(), WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		user := ""
+		if i%2 == 0 {
+			user = "user" + strconv.Itoa(i)
+		}
+		wg.Add(1)
+		go func(user string) {
+			defer wg.Done()
+			resp, err := c.OpenPrintMsg(context.Background(), &PrintMsgReq{SN: "sn", Content: "test", User: user})
+			want := user
+			if want == "" {
+				want = "user"
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if resp.Data != want {
+				t.Errorf("signed as %q, want %q", resp.Data, want)
+			}
+		}(user)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,187 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// BroadcastTarget is one printer of a broadcast with its own content.
+type BroadcastTarget struct {
+	SN      string `json:"sn"`
+	Content string `json:"content"`
+	Times   int    `json:"times,omitempty"`
+	// Label sends the target with Open_printLabelMsg instead of Open_printMsg.
+	Label bool `json:"label,omitempty"`
+}
+
+// BroadcastResult is the outcome of one target.
+type BroadcastResult struct {
+	BroadcastTarget
+	OrderID string `json:"orderId,omitempty"`
+	Err     error  `json:"-"`
+	// Cleared reports the queue of the printer was cleared by the all-or-nothing rollback.
+	Cleared bool `json:"cleared,omitempty"`
+}
+
+// BroadcastOption is the option of Broadcast.
+type BroadcastOption func(o *broadcastOptions)
+
+// broadcastOptions is the options of Broadcast.
+type broadcastOptions struct {
+	concurrency     int
+	allOrNothing    bool
+	rollbackTimeout time.Duration
+	expired         int64
+	backURL         string
+}
+
+// WithBroadcastConcurrency sets the number of targets submitted concurrently, default 4.
+func WithBroadcastConcurrency(n int) BroadcastOption {
+	return func(o *broadcastOptions) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// WithBroadcastAllOrNothing clears the queue of the printers that accepted their ticket with
+// Open_delPrinterSqs when any target fails, and of the printers whose request failed after it
+// may have reached feieyun, such as on a timeout. It clears every ticket waiting on those
+// printers, and tickets already printed cannot be taken back.
+func WithBroadcastAllOrNothing(allOrNothing bool) BroadcastOption {
+	return func(o *broadcastOptions) {
+		o.allOrNothing = allOrNothing
+	}
+}
+
+// WithBroadcastRollbackTimeout bounds the all-or-nothing rollback, default 10 seconds. The rollback
+// runs on its own context, so it still clears the queues after the ctx of Broadcast is done.
+func WithBroadcastRollbackTimeout(timeout time.Duration) BroadcastOption {
+	return func(o *broadcastOptions) {
+		if timeout > 0 {
+			o.rollbackTimeout = timeout
+		}
+	}
+}
+
+// WithBroadcastExpired sets the expired UNIX timestamp of every target.
+func WithBroadcastExpired(expired int64) BroadcastOption {
+	return func(o *broadcastOptions) {
+		o.expired = expired
+	}
+}
+
+// WithBroadcastBackURL sets the callback URL of every target.
+func WithBroadcastBackURL(backURL string) BroadcastOption {
+	return func(o *broadcastOptions) {
+		o.backURL = backURL
+	}
+}
+
+// Broadcast submits the targets concurrently and returns their results in the order of targets.
+// The returned error is a *BatchError listing the failed targets, nil when all succeeded.
+func (c *Client) Broadcast(ctx context.Context, targets []BroadcastTarget, opts ...BroadcastOption) ([]*BroadcastResult, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("feie: broadcast targets is empty")
+	}
+	op := broadcastOptions{concurrency: 4, rollbackTimeout: 10 * time.Second}
+	for _, opt := range opts {
+		opt(&op)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, op.concurrency)
+		results = make([]*BroadcastResult, len(targets))
+	)
+	for i, target := range targets {
+		results[i] = &BroadcastResult{BroadcastTarget: target}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(result *BroadcastResult) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := ctx.Err(); err != nil {
+				result.Err = &notSentError{err: err}
+				return
+			}
+			result.OrderID, result.Err = c.sendJob(ctx, result.job(op))
+		}(results[i])
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, &BroadcastError{SN: result.SN, Err: result.Err})
+		}
+	}
+	if len(errs) == 0 {
+		return results, nil
+	}
+	if op.allOrNothing {
+		// a target failing on timeout usually means ctx is done, the rollback must not depend on it.
+		rollbackCtx, cancel := context.WithTimeout(context.Background(), op.rollbackTimeout)
+		defer cancel()
+		cleared := make(map[string]bool)
+		for _, result := range results {
+			if !result.mayBeQueued() {
+				continue
+			}
+			if !cleared[result.SN] {
+				resp, err := c.OpenDelPrinterSQS(rollbackCtx, &DelPrinterSQSReq{SN: result.SN})
+				if err == nil && resp.Ret != 0 {
+					err = &APIError{API: delPrinterSqs, Ret: resp.Ret, Msg: resp.Msg}
+				}
+				if err != nil {
+					errs = append(errs, &BroadcastError{SN: result.SN, Err: err, Rollback: true})
+					continue
+				}
+				cleared[result.SN] = true
+			}
+			result.Cleared = true
+		}
+	}
+	return results, &BatchError{Errors: errs}
+}
+
+// mayBeQueued reports whether the ticket of the target may be waiting on the printer: it was
+// accepted, or its request failed without an answer from feieyun and without proof it was not sent.
+func (r *BroadcastResult) mayBeQueued() bool {
+	var apiErr *APIError
+	return r.Err == nil || (!errors.As(r.Err, &apiErr) && !requestNotSent(r.Err))
+}
+
+// job returns the Job of the target.
+func (r *BroadcastResult) job(op broadcastOptions) *Job {
+	if r.Label {
+		return &Job{Kind: JobPrintLabelMsg, Label: &PrintLabelMsgReq{
+			SN: r.SN, Content: r.Content, Times: r.Times, Expired: op.expired, BackURL: op.backURL,
+		}}
+	}
+	return &Job{Kind: JobPrintMsg, Msg: &PrintMsgReq{
+		SN: r.SN, Content: r.Content, Times: r.Times, Expired: op.expired, BackURL: op.backURL,
+	}}
+}
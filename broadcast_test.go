@@ -0,0 +1,159 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_Broadcast(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		cleared []string
+	)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		switch {
+		case form[APINameField] == delPrinterSqs:
+			mu.Lock()
+			cleared = append(cleared, form[SNField])
+			mu.Unlock()
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": true}
+		case form[SNField] == "bar" && form[ContentField] == "fail":
+			return map[string]interface{}{"ret": 1002, "msg": "打印机编号错误"}
+		default:
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_" + form[APINameField] + "_" + form[TimesField]}
+		}
+	})
+	var (
+		ctx     = context.Background()
+		c       = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		targets = []BroadcastTarget{
+			{SN: "kitchen", Content: "2 noodles", Times: 2},
+			{SN: "bar", Content: "1 beer"},
+			{SN: "label", Content: "cup", Label: true},
+		}
+	)
+	results, err := c.Broadcast(ctx, targets, WithBroadcastConcurrency(2))
+	if err != nil {
+		t.Fatalf("Broadcast() error = %v", err)
+	}
+	want := []string{"kitchen_Open_printMsg_2", "bar_Open_printMsg_", "label_Open_printLabelMsg_"}
+	for i, result := range results {
+		if result.OrderID != want[i] || result.Err != nil {
+			t.Errorf("Broadcast() result %d = %+v, want order %s", i, result, want[i])
+		}
+	}
+
+	targets[1].Content = "fail"
+	results, err = c.Broadcast(ctx, targets, WithBroadcastAllOrNothing(true))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Ret != 1002 {
+		t.Fatalf("Broadcast() error = %v, want the ret of the failed target", err)
+	}
+	if results[1].Err == nil || results[1].Cleared || !results[0].Cleared || !results[2].Cleared {
+		t.Errorf("Broadcast() results = %+v %+v %+v", results[0], results[1], results[2])
+	}
+	if len(cleared) != 2 {
+		t.Errorf("cleared = %v, want kitchen and label", cleared)
+	}
+}
+
+func TestClient_Broadcast_RollbackAfterTimeout(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		cleared []string
+	)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		if form[APINameField] == delPrinterSqs {
+			mu.Lock()
+			cleared = append(cleared, form[SNField])
+			mu.Unlock()
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": true}
+		}
+		time.Sleep(150 * time.Millisecond)
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_1"}
+	})
+	next := NewHTTPTransport(0)
+	transport := TransportFunc(func(ctx context.Context, gateway string, form map[string]string) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return next.Do(ctx, gateway, form)
+	})
+	c := New(context.Background(), WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL), WithTransport(transport))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	results, err := c.Broadcast(ctx, []BroadcastTarget{{SN: "kitchen", Content: "a"}, {SN: "bar", Content: "b"}},
+		WithBroadcastConcurrency(1), WithBroadcastAllOrNothing(true), WithBroadcastRollbackTimeout(time.Second))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Broadcast() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !results[0].Cleared || len(cleared) != 1 || cleared[0] != "kitchen" {
+		t.Errorf("Broadcast() rollback cleared %v, result %+v, want kitchen", cleared, results[0])
+	}
+}
+
+func TestClient_Broadcast_RollbackAmbiguous(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		cleared []string
+	)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		switch {
+		case form[APINameField] == delPrinterSqs:
+			mu.Lock()
+			cleared = append(cleared, form[SNField])
+			mu.Unlock()
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": true}
+		case form[SNField] == "label":
+			return map[string]interface{}{"ret": 1002, "msg": "打印机编号错误"}
+		default:
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_1"}
+		}
+	})
+	next := NewHTTPTransport(0)
+	transport := TransportFunc(func(ctx context.Context, gateway string, form map[string]string) ([]byte, error) {
+		body, err := next.Do(ctx, gateway, form)
+		if form[SNField] == "bar" && form[APINameField] == printMsg {
+			// the gateway accepted the ticket, the response is lost on the way back
+			return nil, errors.New("read: connection reset by peer")
+		}
+		return body, err
+	})
+	var (
+		ctx     = context.Background()
+		c       = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL), WithTransport(transport))
+		targets = []BroadcastTarget{{SN: "kitchen", Content: "a"}, {SN: "bar", Content: "b"}, {SN: "label", Content: "c"}}
+	)
+	results, err := c.Broadcast(ctx, targets, WithBroadcastConcurrency(1), WithBroadcastAllOrNothing(true))
+	if err == nil {
+		t.Fatal("Broadcast() succeeded, want the failed targets")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !results[0].Cleared || !results[1].Cleared || results[2].Cleared || len(cleared) != 2 {
+		t.Errorf("Broadcast() cleared %v, results %+v %+v %+v, want kitchen and bar", cleared, results[0], results[1], results[2])
+	}
+}
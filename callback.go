@@ -0,0 +1,77 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+// CallbackSuccess is the body feieyun expects back from a callback, any other reply is pushed again.
+const CallbackSuccess = "SUCCESS"
+
+// CallbackFunc handles a callback verified by AsyncPrinterResult, such as Tracker.HandleCallback.
+// Returning an error replies 500 so feieyun pushes the callback again.
+type CallbackFunc func(ctx context.Context, result *AsyncPrinterResultResp) error
+
+// NewCallbackHandler returns an http.Handler receiving the order status callbacks posted to the
+// backurl. It verifies the sign with the public key set by WithPublicKey, calls fn and replies
+// SUCCESS; a callback failing the verification is rejected with 403 and fn is not called.
+func NewCallbackHandler(c *Client, fn CallbackFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req := &AsyncPrinterResultReq{OrderID: r.PostForm.Get("orderId"), Sign: r.PostForm.Get("sign")}
+		var err error
+		if req.Status, err = strconv.Atoi(r.PostForm.Get("status")); err != nil {
+			http.Error(w, "invalid status", http.StatusBadRequest)
+			return
+		}
+		if req.Stime, err = strconv.Atoi(r.PostForm.Get("stime")); err != nil {
+			http.Error(w, "invalid stime", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		result, err := c.AsyncPrinterResult(ctx, req)
+		if err != nil || result == nil || !result.VerifySign {
+			c.logEvent(ctx, hlog.LevelWarn, "feie callback rejected",
+				Field{Key: "order_id", Value: req.OrderID}, Field{Key: "error", Value: ErrInvalidSign})
+			http.Error(w, ErrInvalidSign.Error(), http.StatusForbidden)
+			return
+		}
+		if fn != nil {
+			if err = fn(ctx, result); err != nil {
+				c.logEvent(ctx, hlog.LevelWarn, "feie callback failed",
+					Field{Key: "order_id", Value: req.OrderID}, Field{Key: "error", Value: err})
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		_, _ = w.Write([]byte(CallbackSuccess))
+	})
+}
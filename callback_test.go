@@ -0,0 +1,114 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// signCallback returns the base64 public key and the sign of the callback content.
+func signCallback(t *testing.T, content string) (string, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := sha256.Sum256([]byte(content))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(der), base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestNewCallbackHandler(t *testing.T) {
+	const orderID = "816501678_20160919184316_1419533539"
+	publicKey, sign := signCallback(t, "orderId="+orderID+"&status=1&stime=1625194910")
+	c := New(context.Background(), WithPublicKey(publicKey))
+
+	tests := []struct {
+		name     string
+		form     url.Values
+		fnErr    error
+		wantCode int
+		wantCall bool
+	}{
+		{
+			name:     "verified",
+			form:     url.Values{"orderId": {orderID}, "status": {"1"}, "stime": {"1625194910"}, "sign": {sign}},
+			wantCode: http.StatusOK,
+			wantCall: true,
+		},
+		{
+			name:     "tampered",
+			form:     url.Values{"orderId": {orderID}, "status": {"0"}, "stime": {"1625194910"}, "sign": {sign}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "bad status",
+			form:     url.Values{"orderId": {orderID}, "status": {"x"}, "stime": {"1625194910"}, "sign": {sign}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "handler error",
+			form:     url.Values{"orderId": {orderID}, "status": {"1"}, "stime": {"1625194910"}, "sign": {sign}},
+			fnErr:    errors.New("store down"),
+			wantCode: http.StatusInternalServerError,
+			wantCall: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called *AsyncPrinterResultResp
+			h := NewCallbackHandler(c, func(ctx context.Context, result *AsyncPrinterResultResp) error {
+				called = result
+				return tt.fnErr
+			})
+			r := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if (called != nil) != tt.wantCall {
+				t.Fatalf("fn called = %v, want %v", called != nil, tt.wantCall)
+			}
+			if tt.wantCode == http.StatusOK && (w.Body.String() != CallbackSuccess || called.OrderID != orderID || called.Status != 1) {
+				t.Errorf("body = %q, result = %+v", w.Body, called)
+			}
+		})
+	}
+}
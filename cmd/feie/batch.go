@@ -0,0 +1,340 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/houseme/feie"
+)
+
+// batchJob is one line of the batch input.
+//
+//	{"sn":"816501678","content":"<CB>测试</CB>","times":2,"expired":"30m"}
+//	{"sn":"816501678","template":"{{.name}} x{{.qty}}","data":{"name":"咖啡","qty":2}}
+type batchJob struct {
+	ID             string                 `json:"id,omitempty"`
+	SN             string                 `json:"sn"`
+	Content        string                 `json:"content,omitempty"`
+	Template       string                 `json:"template,omitempty"`
+	Data           map[string]interface{} `json:"data,omitempty"`
+	Times          int                    `json:"times,omitempty"`
+	Expired        expiry                 `json:"expired,omitempty"`
+	BackURL        string                 `json:"backurl,omitempty"`
+	Label          bool                   `json:"label,omitempty"`
+	IdempotencyKey string                 `json:"idempotency_key,omitempty"`
+}
+
+// expiry is a UNIX timestamp, or a duration from the submission such as "30m".
+type expiry struct {
+	at  int64
+	dur time.Duration
+}
+
+// UnmarshalJSON accepts a number or a duration string.
+func (e *expiry) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if data[0] != '"' {
+		return json.Unmarshal(data, &e.at)
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if at, err := strconv.ParseInt(s, 10, 64); err == nil {
+		e.at = at
+		return nil
+	}
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("expired %q is neither a UNIX timestamp nor a duration", s)
+	}
+	e.dur = dur
+	return nil
+}
+
+// unix returns the UNIX timestamp the job expires at, 0 when not set.
+func (e expiry) unix(now time.Time) int64 {
+	if e.dur > 0 {
+		return now.Add(e.dur).Unix()
+	}
+	return e.at
+}
+
+// batchResult is one line of the results file.
+type batchResult struct {
+	Line    int             `json:"line"`
+	ID      string          `json:"id,omitempty"`
+	SN      string          `json:"sn,omitempty"`
+	OrderID string          `json:"order_id,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Job     json.RawMessage `json:"job"`
+}
+
+// batchInput is a parsed line of the batch input.
+type batchInput struct {
+	line int
+	text []byte
+	raw  json.RawMessage
+	job  *batchJob
+	err  error
+}
+
+func runBatch(ctx context.Context, e *env, args []string) error {
+	var (
+		file, results, failed, tmplFile string
+		concurrency                     int
+		rate                            float64
+	)
+	fs := e.flags("batch", "")
+	fs.StringVar(&file, "file", "-", "JSON Lines 打印任务文件，- 表示标准输入")
+	fs.StringVar(&results, "results", "", "结果文件，每行一个任务的订单ID或错误(必填)")
+	fs.StringVar(&failed, "failed", "", "失败任务文件，可直接作为 -file 重新执行")
+	fs.StringVar(&tmplFile, "template", "", "默认模板文件，用于只有 data 的任务")
+	fs.IntVar(&concurrency, "concurrency", 4, "并发数")
+	fs.Float64Var(&rate, "rate", 0, "每秒最多提交的任务数，0 表示不限制")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	if results == "" {
+		return fmt.Errorf("%w: -results is required", errUsage)
+	}
+	if concurrency < 1 || rate < 0 {
+		return fmt.Errorf("%w: -concurrency must be positive and -rate must not be negative", errUsage)
+	}
+	var defaultTmpl *template.Template
+	if tmplFile != "" {
+		content, err := os.ReadFile(tmplFile)
+		if err != nil {
+			return err
+		}
+		if defaultTmpl, err = template.New(tmplFile).Option("missingkey=error").Parse(string(content)); err != nil {
+			return fmt.Errorf("%w: -template: %v", errUsage, err)
+		}
+	}
+
+	var in io.Reader = e.stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	resultsFile, err := os.Create(results)
+	if err != nil {
+		return err
+	}
+	defer resultsFile.Close()
+	var failedFile *os.File
+	if failed != "" {
+		if failedFile, err = os.Create(failed); err != nil {
+			return err
+		}
+		defer failedFile.Close()
+	}
+
+	c, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	inputs := make(chan *batchInput)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(inputs)
+		readErr <- readBatch(ctx, in, inputs)
+	}()
+
+	var limit <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		limit = ticker.C
+	}
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		total, bad int
+		writeErr   error
+	)
+	record := func(input *batchInput, r *batchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		total++
+		line, _ := json.Marshal(r)
+		if _, err := resultsFile.Write(append(line, '\n')); err != nil && writeErr == nil {
+			writeErr = err
+		}
+		if r.Error == "" {
+			return
+		}
+		bad++
+		if failedFile != nil {
+			if _, err := failedFile.Write(append(input.text, '\n')); err != nil && writeErr == nil {
+				writeErr = err
+			}
+		}
+	}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for input := range inputs {
+				r := &batchResult{Line: input.line, Job: input.raw}
+				if input.job != nil {
+					r.ID, r.SN = input.job.ID, input.job.SN
+				}
+				if input.err == nil && limit != nil {
+					select {
+					case <-limit:
+					case <-ctx.Done():
+						input.err = ctx.Err()
+					}
+				}
+				if input.err == nil {
+					r.OrderID, input.err = submitBatchJob(ctx, c, input.job, defaultTmpl)
+				}
+				if input.err != nil {
+					r.Error = input.err.Error()
+				}
+				record(input, r)
+			}
+		}()
+	}
+	wg.Wait()
+	if err = <-readErr; err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	summary := map[string]int{"total": total, "ok": total - bad, "failed": bad}
+	if err = e.write(summary, []string{"TOTAL", "OK", "FAILED"}, [][]string{{strconv.Itoa(total), strconv.Itoa(total - bad), strconv.Itoa(bad)}}); err != nil {
+		return err
+	}
+	if bad > 0 {
+		return fmt.Errorf("%d of %d jobs failed, see %s", bad, total, results)
+	}
+	return nil
+}
+
+// readBatch sends every non-empty line of r to inputs, a line that is not a valid job keeps its error.
+func readBatch(ctx context.Context, r io.Reader, inputs chan<- *batchInput) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		input := &batchInput{line: line, text: append([]byte{}, text...)}
+		input.raw = input.text
+		var job batchJob
+		if err := json.Unmarshal(text, &job); err != nil {
+			input.err = fmt.Errorf("line %d: %w", line, err)
+			input.raw, _ = json.Marshal(string(text))
+		} else {
+			input.job = &job
+		}
+		select {
+		case inputs <- input:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// submitBatchJob renders and prints the job, it returns the order ID.
+func submitBatchJob(ctx context.Context, c *feie.Client, job *batchJob, defaultTmpl *template.Template) (string, error) {
+	if strings.TrimSpace(job.SN) == "" {
+		return "", errors.New("sn is required")
+	}
+	content, err := job.render(defaultTmpl)
+	if err != nil {
+		return "", err
+	}
+	expired := job.Expired.unix(time.Now())
+	if job.Label {
+		resp, err := c.OpenPrintLabelMsg(ctx, &feie.PrintLabelMsgReq{
+			SN: job.SN, Content: content, Times: job.Times, Expired: expired, BackURL: job.BackURL, IdempotencyKey: job.IdempotencyKey,
+		})
+		if err != nil {
+			return "", err
+		}
+		if resp.Ret != 0 {
+			return "", &feie.APIError{API: "Open_printLabelMsg", Ret: resp.Ret, Msg: resp.Msg}
+		}
+		return resp.Data, nil
+	}
+	resp, err := c.OpenPrintMsg(ctx, &feie.PrintMsgReq{
+		SN: job.SN, Content: content, Times: job.Times, Expired: expired, BackURL: job.BackURL, IdempotencyKey: job.IdempotencyKey,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.Ret != 0 {
+		return "", &feie.APIError{API: "Open_printMsg", Ret: resp.Ret, Msg: resp.Msg}
+	}
+	return resp.Data, nil
+}
+
+// render returns the content, or executes the template of the job or the default one with its data.
+func (j *batchJob) render(defaultTmpl *template.Template) (string, error) {
+	if j.Content != "" {
+		if j.Template != "" {
+			return "", errors.New("content and template are exclusive")
+		}
+		return j.Content, nil
+	}
+	tmpl := defaultTmpl
+	if j.Template != "" {
+		var err error
+		if tmpl, err = template.New("job").Option("missingkey=error").Parse(j.Template); err != nil {
+			return "", err
+		}
+	}
+	if tmpl == nil {
+		return "", errors.New("content or template is required")
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, j.Data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,114 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/houseme/feie/feietest"
+)
+
+func TestRunBatch(t *testing.T) {
+	srv := feietest.NewServer()
+	defer srv.Close()
+	srv.AddPrinter(feietest.DefaultUser, "sn1", "key1")
+
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "ticket.tmpl")
+	if err := os.WriteFile(tmpl, []byte("{{.name}} x{{.qty}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jobs := strings.Join([]string{
+		`{"id":"a","sn":"sn1","content":"hello","times":2,"expired":"30m"}`,
+		`{"id":"b","sn":"sn1","template":"<CB>{{.table}}</CB>","data":{"table":"A1"}}`,
+		`{"id":"c","sn":"sn1","data":{"name":"咖啡","qty":2},"label":true}`,
+		`{"id":"d","sn":"sn9","content":"unknown printer"}`,
+		`{"id":"e","sn":"sn1","data":{"name":"missing qty"}}`,
+		`not json`,
+		``,
+	}, "\n")
+	results, failed := filepath.Join(dir, "results.jsonl"), filepath.Join(dir, "failed.jsonl")
+
+	code, stdout, stderr := runCLI(t, srv, jobs, "batch", "-results", results, "-failed", failed, "-template", tmpl, "-rate", "100", "-o", "json")
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstdout: %s\nstderr: %s", code, stdout, stderr)
+	}
+	if !strings.Contains(stdout, `"failed": 3`) || !strings.Contains(stdout, `"ok": 3`) {
+		t.Errorf("summary = %s", stdout)
+	}
+
+	got := make(map[string]*batchResult)
+	readLines(t, results, func(line []byte) {
+		var r batchResult
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatal(err)
+		}
+		got[r.ID] = &r
+	})
+	for _, id := range []string{"a", "b", "c"} {
+		if got[id] == nil || got[id].OrderID == "" || got[id].Error != "" {
+			t.Errorf("result %s = %+v, want an order id", id, got[id])
+		}
+	}
+	for _, id := range []string{"d", "e", ""} {
+		if got[id] == nil || got[id].Error == "" {
+			t.Errorf("result %q = %+v, want an error", id, got[id])
+		}
+	}
+	if o, _ := srv.Order(got["c"].OrderID); o.Content != "咖啡 x2" || o.API != "Open_printLabelMsg" {
+		t.Errorf("order c = %+v", o)
+	}
+	if o, _ := srv.Order(got["a"].OrderID); o.Times != 2 || o.Expired.IsZero() {
+		t.Errorf("order a = %+v", o)
+	}
+
+	var lines int
+	readLines(t, failed, func([]byte) { lines++ })
+	if lines != 3 {
+		t.Errorf("failed file has %d lines, want 3", lines)
+	}
+
+	srv.AddPrinter(feietest.DefaultUser, "sn9", "key9")
+	rerun, _ := os.ReadFile(failed)
+	code, _, _ = runCLI(t, srv, string(rerun), "batch", "-results", filepath.Join(dir, "rerun.jsonl"), "-template", tmpl)
+	if code != 1 {
+		t.Errorf("rerun exit code = %d, want 1 for the jobs that stay invalid", code)
+	}
+	if orders := srv.Orders("sn9"); len(orders) != 1 {
+		t.Errorf("rerun printed %d orders on sn9, want 1", len(orders))
+	}
+}
+
+func readLines(t *testing.T, path string, fn func([]byte)) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fn(scanner.Bytes())
+	}
+}
@@ -0,0 +1,192 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/houseme/feie"
+)
+
+// callbackEvent is a verified callback as printed, forwarded and appended to -out.
+type callbackEvent struct {
+	ReceivedAt time.Time `json:"received_at"`
+	OrderID    string    `json:"order_id"`
+	SN         string    `json:"sn,omitempty"`
+	Status     int       `json:"status"`
+	Printed    bool      `json:"printed"`
+	STime      int       `json:"stime"`
+}
+
+func runCallback(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || args[0] != "serve" {
+		return fmt.Errorf("%w: usage: feie callback serve [flags]", errUsage)
+	}
+	var addr, pubkey, forward, out, path string
+	fs := e.flags("callback serve", "")
+	fs.StringVar(&addr, "addr", ":8080", "监听地址")
+	fs.StringVar(&path, "path", "/", "回调路径")
+	fs.StringVar(&pubkey, "pubkey", "", "飞鹅云公钥文件，PEM 或 base64 DER(必填)")
+	fs.StringVar(&forward, "forward", "", "将验签通过的事件以 JSON POST 转发到该地址")
+	fs.StringVar(&out, "out", "", "将验签通过的事件追加到 JSON Lines 文件")
+	if err := e.parse(fs, args[1:]); err != nil {
+		return err
+	}
+	if pubkey == "" {
+		return fmt.Errorf("%w: -pubkey is required", errUsage)
+	}
+	handler, closeFn, err := newCallbackHandler(ctx, e, pubkey, forward, out)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	fmt.Fprintf(e.stderr, "feie callback listening on %s%s\n", ln.Addr(), path)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+	select {
+	case err = <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err = srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		<-errCh
+		return nil
+	}
+}
+
+// readPublicKey reads a PEM or base64 DER public key file and returns the base64 DER.
+func readPublicKey(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if block, _ := pem.Decode(content); block != nil {
+		return base64.StdEncoding.EncodeToString(block.Bytes), nil
+	}
+	key := strings.Join(strings.Fields(string(content)), "")
+	if key == "" {
+		return "", fmt.Errorf("public key %s is empty", path)
+	}
+	return key, nil
+}
+
+// newCallbackHandler returns the handler printing, forwarding and appending verified events,
+// and the func closing the -out file.
+func newCallbackHandler(ctx context.Context, e *env, pubkey, forward, out string) (http.Handler, func(), error) {
+	key, err := readPublicKey(pubkey)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		mu     sync.Mutex
+		file   *os.File
+		client = &http.Client{Timeout: 5 * time.Second}
+	)
+	if out != "" {
+		if file, err = os.OpenFile(out, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err != nil {
+			return nil, nil, err
+		}
+	}
+	closeFn := func() {
+		if file != nil {
+			_ = file.Close()
+		}
+	}
+	c := feie.New(ctx, feie.WithPublicKey(key))
+	handler := feie.NewCallbackHandler(c, func(ctx context.Context, result *feie.AsyncPrinterResultResp) error {
+		event := &callbackEvent{
+			ReceivedAt: time.Now(),
+			OrderID:    result.OrderID,
+			SN:         feie.OrderID(result.OrderID).SN(),
+			Status:     result.Status,
+			Printed:    result.Status == 1,
+			STime:      result.Stime,
+		}
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if e.output == "json" {
+			fmt.Fprintln(e.stdout, string(line))
+		} else {
+			state := "failed"
+			if event.Printed {
+				state = "printed"
+			}
+			fmt.Fprintf(e.stdout, "%s  order=%s  sn=%s  status=%d(%s)  stime=%s\n",
+				event.ReceivedAt.Format(time.RFC3339), event.OrderID, event.SN, event.Status, state,
+				time.Unix(int64(event.STime), 0).In(feie.Shanghai).Format(time.RFC3339))
+		}
+		if file != nil {
+			if _, err = file.Write(append(line, '\n')); err != nil {
+				return err
+			}
+		}
+		if forward != "" {
+			return forwardEvent(ctx, client, forward, line)
+		}
+		return nil
+	})
+	return handler, closeFn, nil
+}
+
+// forwardEvent posts the event as JSON, a failure makes feieyun push the callback again.
+func forwardEvent(ctx context.Context, client *http.Client, target string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New("forward to " + target + " got " + resp.Status)
+	}
+	return nil
+}
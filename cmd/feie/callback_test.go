@@ -0,0 +1,122 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/houseme/feie/feietest"
+)
+
+func TestCallbackHandler(t *testing.T) {
+	ctx := context.Background()
+	gw := feietest.NewServer()
+	defer gw.Close()
+	publicKey, err := gw.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := base64.StdEncoding.DecodeString(publicKey)
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key.pem")
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu        sync.Mutex
+		forwarded []callbackEvent
+	)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event callbackEvent
+		_ = json.Unmarshal(body, &event)
+		mu.Lock()
+		forwarded = append(forwarded, event)
+		mu.Unlock()
+	}))
+	defer target.Close()
+
+	var stdout bytes.Buffer
+	out := filepath.Join(dir, "events.jsonl")
+	e := &env{stdout: &stdout, stderr: io.Discard, output: "table"}
+	handler, closeFn, err := newCallbackHandler(ctx, e, keyFile, target.URL, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := httptest.NewServer(handler)
+	defer receiver.Close()
+
+	const orderID = "816501678_20160919184316_1419533539"
+	if err = gw.PostCallback(ctx, receiver.URL, orderID, feietest.CallbackPrinted, time.Now().Unix()); err != nil {
+		t.Fatalf("PostCallback() error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), "order="+orderID) || !strings.Contains(stdout.String(), "sn=816501678") {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	mu.Lock()
+	if len(forwarded) != 1 || !forwarded[0].Printed || forwarded[0].OrderID != orderID {
+		t.Errorf("forwarded = %+v", forwarded)
+	}
+	mu.Unlock()
+	closeFn()
+	content, _ := os.ReadFile(out)
+	if lines := strings.Count(string(content), "\n"); lines != 1 {
+		t.Errorf("events file has %d lines, want 1", lines)
+	}
+
+	other := feietest.NewServer()
+	defer other.Close()
+	if err = other.PostCallback(ctx, receiver.URL, orderID, feietest.CallbackPrinted, time.Now().Unix()); err == nil {
+		t.Error("PostCallback() signed with another key succeeded")
+	}
+}
+
+func TestRunCallback_Serve(t *testing.T) {
+	gw := feietest.NewServer()
+	defer gw.Close()
+	publicKey, _ := gw.PublicKey()
+	keyFile := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(keyFile, []byte(publicKey), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	code := run(ctx, []string{"callback", "serve", "-addr", "127.0.0.1:0", "-pubkey", keyFile}, nil, &stdout, &stderr)
+	if code != 0 || !strings.Contains(stderr.String(), "listening on 127.0.0.1:") {
+		t.Errorf("callback serve = %d, stderr %q", code, stderr.String())
+	}
+	if code = run(context.Background(), []string{"callback", "serve"}, nil, &stdout, &stderr); code != 2 {
+		t.Errorf("callback serve without -pubkey = %d, want 2", code)
+	}
+}
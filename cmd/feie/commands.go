@@ -0,0 +1,417 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/houseme/feie"
+)
+
+// printFlags are the flags shared by print and print-label.
+type printFlags struct {
+	sn, content, file, backURL, key string
+	times                           int
+	expired                         time.Duration
+}
+
+// register returns the flag set of the command with the print flags registered.
+func (p *printFlags) register(e *env, name string) *flag.FlagSet {
+	fs := e.flags(name, "")
+	fs.StringVar(&p.sn, "sn", "", "打印机编号(必填)")
+	fs.StringVar(&p.content, "content", "", "打印内容")
+	fs.StringVar(&p.file, "file", "", "从文件读取打印内容，- 表示标准输入")
+	fs.IntVar(&p.times, "times", 1, "打印联数")
+	fs.DurationVar(&p.expired, "expired", 0, "订单失效时间，如 10m，0 表示不失效")
+	fs.StringVar(&p.backURL, "backurl", "", "订单状态回调地址")
+	fs.StringVar(&p.key, "idempotency-key", "", "幂等键")
+	return fs
+}
+
+// readContent returns -content, or the content of -file.
+func (p *printFlags) readContent(e *env) (string, error) {
+	if p.sn == "" {
+		return "", fmt.Errorf("%w: -sn is required", errUsage)
+	}
+	if (p.content == "") == (p.file == "") {
+		return "", fmt.Errorf("%w: exactly one of -content and -file is required", errUsage)
+	}
+	if p.content != "" {
+		return p.content, nil
+	}
+	content, err := readFile(e, p.file)
+	return string(content), err
+}
+
+// expiredAt returns the UNIX timestamp of -expired, 0 when not set.
+func (p *printFlags) expiredAt() int64 {
+	if p.expired <= 0 {
+		return 0
+	}
+	return time.Now().Add(p.expired).Unix()
+}
+
+// readFile reads the file, - reads the standard input.
+func readFile(e *env, path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(e.stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func runPrint(ctx context.Context, e *env, args []string) error {
+	var p printFlags
+	fs := p.register(e, "print")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	content, err := p.readContent(e)
+	if err != nil {
+		return err
+	}
+	c, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := c.OpenPrintMsg(ctx, &feie.PrintMsgReq{
+		SN:             p.sn,
+		Content:        content,
+		Times:          p.times,
+		Expired:        p.expiredAt(),
+		BackURL:        p.backURL,
+		IdempotencyKey: p.key,
+	})
+	if err != nil {
+		return err
+	}
+	return e.writeResp("Open_printMsg", resp, resp.Ret, resp.Msg, resp.Data)
+}
+
+func runPrintLabel(ctx context.Context, e *env, args []string) error {
+	var (
+		p   printFlags
+		img string
+	)
+	fs := p.register(e, "print-label")
+	fs.StringVar(&img, "img", "", "图片文件，配合<IMG>标签使用")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	content, err := p.readContent(e)
+	if err != nil {
+		return err
+	}
+	req := &feie.PrintLabelMsgReq{
+		SN:             p.sn,
+		Content:        content,
+		Times:          p.times,
+		Expired:        p.expiredAt(),
+		BackURL:        p.backURL,
+		IdempotencyKey: p.key,
+	}
+	if img != "" {
+		raw, err := os.ReadFile(img)
+		if err != nil {
+			return err
+		}
+		req.Img = base64.StdEncoding.EncodeToString(raw)
+	}
+	c, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := c.OpenPrintLabelMsg(ctx, req)
+	if err != nil {
+		return err
+	}
+	return e.writeResp("Open_printLabelMsg", resp, resp.Ret, resp.Msg, resp.Data)
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func runAdd(ctx context.Context, e *env, args []string) error {
+	var (
+		printers stringList
+		file     string
+	)
+	fs := e.flags("add", "")
+	fs.Var(&printers, "printer", "打印机 sn#key#remark#carnum，可重复")
+	fs.StringVar(&file, "file", "", "从文件读取打印机，每行 sn#key#remark#carnum，- 表示标准输入")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	lines := []string(printers)
+	if file != "" {
+		content, err := readFile(e, file)
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(content)))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "//") {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("%w: -printer or -file is required", errUsage)
+	}
+	specs := make([]feie.PrinterSpec, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.SplitN(line, "#", 4)
+		for len(fields) < 4 {
+			fields = append(fields, "")
+		}
+		specs = append(specs, feie.PrinterSpec{SN: fields[0], Key: fields[1], Remark: fields[2], CardNumber: fields[3]})
+	}
+	c, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := c.AddPrinters(ctx, specs)
+	return e.writeResults(results, err)
+}
+
+func runDelete(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("delete", "sn...")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("%w: at least one sn is required", errUsage)
+	}
+	c, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := c.DeletePrinters(ctx, fs.Args())
+	return e.writeResults(results, err)
+}
+
+// writeResults writes per printer results, a failed printer makes the command fail.
+func (e *env) writeResults(results []*feie.PrinterResult, err error) error {
+	rows := make([][]string, 0, len(results))
+	failed := 0
+	for _, r := range results {
+		if !r.OK {
+			failed++
+		}
+		rows = append(rows, []string{r.SN, strconv.FormatBool(r.OK), r.Reason})
+	}
+	if len(results) > 0 {
+		if werr := e.write(results, []string{"SN", "OK", "REASON"}, rows); werr != nil {
+			return werr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d printers failed", failed, len(results))
+	}
+	return nil
+}
+
+func runEdit(ctx context.Context, e *env, args []string) error {
+	var sn, name, phone string
+	fs := e.flags("edit", "")
+	fs.StringVar(&sn, "sn", "", "打印机编号(必填)")
+	fs.StringVar(&name, "name", "", "打印机备注名称(必填)")
+	fs.StringVar(&phone, "phone", "", "打印机流量卡号码")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	if sn == "" || name == "" {
+		return fmt.Errorf("%w: -sn and -name are required", errUsage)
+	}
+	c, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := c.OpenPrinterEdit(ctx, &feie.PrinterEditReq{SN: sn, Name: name, PhoneNum: phone})
+	if err != nil {
+		return err
+	}
+	return e.writeResp("Open_printerEdit", resp, resp.Ret, resp.Msg, resp.Data)
+}
+
+func runClearQueue(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("clear-queue", "sn")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: exactly one sn is required", errUsage)
+	}
+	c, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := c.OpenDelPrinterSQS(ctx, &feie.DelPrinterSQSReq{SN: fs.Arg(0)})
+	if err != nil {
+		return err
+	}
+	return e.writeResp("Open_delPrinterSqs", resp, resp.Ret, resp.Msg, resp.Data)
+}
+
+func runOrderState(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("order-state", "orderid")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: exactly one order id is required", errUsage)
+	}
+	c, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := c.OpenQueryOrderState(ctx, &feie.QueryOrderStateReq{OrderID: fs.Arg(0)})
+	if err != nil {
+		return err
+	}
+	return e.writeResp("Open_queryOrderState", resp, resp.Ret, resp.Msg, resp.Data)
+}
+
+func runStats(ctx context.Context, e *env, args []string) error {
+	var (
+		sns      stringList
+		from, to string
+	)
+	fs := e.flags("stats", "")
+	fs.Var(&sns, "sn", "打印机编号，可重复或用逗号分隔(必填)")
+	fs.StringVar(&from, "from", "", "开始日期 2006-01-02，默认今天")
+	fs.StringVar(&to, "to", "", "结束日期 2006-01-02，默认等于开始日期")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	var list []string
+	for _, v := range sns {
+		for _, sn := range strings.Split(v, ",") {
+			if sn = strings.TrimSpace(sn); sn != "" {
+				list = append(list, sn)
+			}
+		}
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("%w: -sn is required", errUsage)
+	}
+	start, end := time.Now(), time.Time{}
+	var err error
+	if from != "" {
+		if start, err = time.ParseInLocation("2006-01-02", from, feie.Shanghai); err != nil {
+			return fmt.Errorf("%w: -from: %v", errUsage, err)
+		}
+	}
+	end = start
+	if to != "" {
+		if end, err = time.ParseInLocation("2006-01-02", to, feie.Shanghai); err != nil {
+			return fmt.Errorf("%w: -to: %v", errUsage, err)
+		}
+	}
+	c, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	report, err := c.QueryStats(ctx, list, start, end)
+	if report == nil {
+		return err
+	}
+	if e.output == "json" {
+		if werr := report.WriteJSON(e.stdout); werr != nil {
+			return werr
+		}
+		return err
+	}
+	var rows [][]string
+	for _, p := range report.Printers {
+		for _, d := range p.Days {
+			rows = append(rows, []string{d.SN, d.Date, strconv.Itoa(d.Printed), strconv.Itoa(d.Waiting), d.Error})
+		}
+		rows = append(rows, []string{p.SN, "total", strconv.Itoa(p.Printed), strconv.Itoa(p.Waiting), ""})
+	}
+	rows = append(rows, []string{"total", report.From + "~" + report.To, strconv.Itoa(report.Printed), strconv.Itoa(report.Waiting), ""})
+	if werr := e.write(report, []string{"SN", "DATE", "PRINTED", "WAITING", "ERROR"}, rows); werr != nil {
+		return werr
+	}
+	return err
+}
+
+func runStatus(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("status", "sn...")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("%w: at least one sn is required", errUsage)
+	}
+	c, err := e.client(ctx)
+	if err != nil {
+		return err
+	}
+	type status struct {
+		SN     string `json:"sn"`
+		Status string `json:"status"`
+		Ret    int    `json:"ret"`
+		Msg    string `json:"msg"`
+		Data   string `json:"data"`
+	}
+	var (
+		statuses []*status
+		rows     [][]string
+		errs     []error
+	)
+	for _, sn := range fs.Args() {
+		resp, err := c.OpenQueryPrinterStatus(ctx, &feie.QueryPrinterStatusReq{SN: sn})
+		if err != nil {
+			return err
+		}
+		s := &status{SN: sn, Status: resp.Status().String(), Ret: resp.Ret, Msg: resp.Msg, Data: resp.Data}
+		if resp.Ret != 0 {
+			s.Status = feie.PrinterStatusUnknown.String()
+			errs = append(errs, &feie.APIError{API: "Open_queryPrinterStatus", Ret: resp.Ret, Msg: resp.Msg})
+		}
+		statuses = append(statuses, s)
+		rows = append(rows, []string{s.SN, s.Status, s.Data, s.Msg})
+	}
+	if err = e.write(statuses, []string{"SN", "STATUS", "DATA", "MSG"}, rows); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &feie.BatchError{Errors: errs}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+// Command feie calls the feieyun open API from the command line.
+//
+//	feie status -user xxx -ukey xxx 816501678
+//	feie print -sn 816501678 -content '<CB>测试</CB><BR>'
+//	FEIE_USER=xxx FEIE_UKEY=xxx feie stats -sn 816501678 -from 2024-01-01 -to 2024-01-07 -o json
+//	feie callback serve -addr :8080 -pubkey key.pem -out callbacks.jsonl
+//	feie batch -file jobs.jsonl -results results.jsonl -failed failed.jsonl -concurrency 4 -rate 5
+//
+// Credentials are read from flags, then the FEIE_* environment variables, then the YAML,
+// TOML or JSON file given by -config or FEIE_CONFIG, see feie.LoadConfig. The exit code
+// is 1 when the API returns ret != 0 or the call fails, and 2 on usage errors.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"github.com/houseme/feie"
+)
+
+// errUsage marks an error caused by the command line.
+var errUsage = errors.New("usage error")
+
+// command is a subcommand of feie.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, env *env, args []string) error
+}
+
+// commands are the subcommands of feie, in the order shown by help.
+var commands = []*command{
+	{name: "print", summary: "打印订单 Open_printMsg", run: runPrint},
+	{name: "print-label", summary: "标签机打印订单 Open_printLabelMsg", run: runPrintLabel},
+	{name: "add", summary: "批量添加打印机 Open_printerAddlist", run: runAdd},
+	{name: "delete", summary: "批量删除打印机 Open_printerDelList", run: runDelete},
+	{name: "edit", summary: "修改打印机信息 Open_printerEdit", run: runEdit},
+	{name: "clear-queue", summary: "清空待打印队列 Open_delPrinterSqs", run: runClearQueue},
+	{name: "order-state", summary: "查询订单是否打印成功 Open_queryOrderState", run: runOrderState},
+	{name: "stats", summary: "查询打印机订单统计 Open_queryOrderInfoByDate", run: runStats},
+	{name: "status", summary: "查询打印机状态 Open_queryPrinterStatus", run: runStatus},
+	{name: "batch", summary: "从 JSON Lines 文件或标准输入批量打印", run: runBatch},
+	{name: "callback", summary: "callback serve 启动本地订单状态回调接收服务", run: runCallback},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run runs the command line and returns the exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	var cmd *command
+	for _, c := range commands {
+		if c.name == args[0] {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "feie: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr, getenv: os.Getenv}
+	err := cmd.run(ctx, e, args[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "feie %s: %v\n", cmd.name, err)
+		return 2
+	default:
+		fmt.Fprintf(stderr, "feie %s: %v\n", cmd.name, err)
+		return 1
+	}
+}
+
+// usage prints the commands.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: feie <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.summary)
+	}
+	_ = tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `run "feie <command> -h" for the flags of a command.`)
+}
+
+// env is the environment a command runs in.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+
+	user, ukey, gateway, config, output string
+	timeout                             time.Duration
+}
+
+// flags returns the flag set of a command with the common flags registered.
+func (e *env) flags(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.StringVar(&e.user, "user", "", "飞鹅云后台注册用户名，默认 $FEIE_USER")
+	fs.StringVar(&e.ukey, "ukey", "", "飞鹅云后台生成的 UKEY，默认 $FEIE_UKEY")
+	fs.StringVar(&e.gateway, "gateway", "", "网关地址，默认 $FEIE_GATEWAY 或 https://api.feieyun.cn/Api/Open/")
+	fs.StringVar(&e.config, "config", "", "YAML、TOML 或 JSON 配置文件，默认 $FEIE_CONFIG")
+	fs.StringVar(&e.output, "o", "table", "输出格式：table 或 json")
+	fs.DurationVar(&e.timeout, "timeout", 0, "请求超时时间，默认 30s")
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: feie %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the flags and validates the common ones.
+func (e *env) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if e.output != "table" && e.output != "json" {
+		return fmt.Errorf("%w: -o must be table or json", errUsage)
+	}
+	return nil
+}
+
+// client returns the client built from flags, then FEIE_* environment variables, then the config file.
+func (e *env) client(ctx context.Context) (*feie.Client, error) {
+	cfg, err := feie.LoadConfig(first(e.config, e.getenv("FEIE_CONFIG")))
+	if err != nil {
+		return nil, err
+	}
+	cfg.User = first(e.user, cfg.User)
+	cfg.UKey = first(e.ukey, cfg.UKey)
+	cfg.Gateway = first(e.gateway, cfg.Gateway)
+	if e.timeout > 0 {
+		cfg.Timeout = feie.Duration(e.timeout)
+	}
+	c, err := feie.NewFromConfig(ctx, cfg)
+	var cfgErr *feie.ConfigError
+	if errors.As(err, &cfgErr) && (cfgErr.Field == "user" || cfgErr.Field == "ukey") {
+		return nil, fmt.Errorf("%w: user and ukey are required, set -user/-ukey, FEIE_USER/FEIE_UKEY or -config", errUsage)
+	}
+	return c, err
+}
+
+// first returns the first non-empty value.
+func first(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// write writes v as indented JSON with -o json, or the rows as a table.
+func (e *env) write(v interface{}, header []string, rows [][]string) error {
+	if e.output == "json" {
+		content, err := sonic.ConfigStd.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = e.stdout.Write(append(content, '\n'))
+		return err
+	}
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeResp writes a response with ret, msg and data, and returns an *feie.APIError when ret != 0.
+func (e *env) writeResp(api string, resp interface{}, ret int, msg string, data interface{}) error {
+	if err := e.write(resp, []string{"RET", "MSG", "DATA"}, [][]string{{fmt.Sprint(ret), msg, fmt.Sprint(data)}}); err != nil {
+		return err
+	}
+	if ret != 0 {
+		return &feie.APIError{API: api, Ret: ret, Msg: msg}
+	}
+	return nil
+}
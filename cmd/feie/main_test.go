@@ -0,0 +1,125 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/houseme/feie/feietest"
+)
+
+// runCLI runs the command line against srv and returns the exit code, stdout and stderr.
+func runCLI(t *testing.T, srv *feietest.Server, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	if len(args) > 0 && srv != nil {
+		args = append([]string{args[0], "-gateway", srv.URL, "-user", feietest.DefaultUser, "-ukey", feietest.DefaultUKey}, args[1:]...)
+	}
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	srv := feietest.NewServer()
+	defer srv.Close()
+	srv.AddPrinter(feietest.DefaultUser, "sn1", "key1")
+
+	tests := []struct {
+		name     string
+		stdin    string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "no command", wantCode: 2},
+		{name: "unknown command", args: []string{"nope"}, wantCode: 2},
+		{name: "status", args: []string{"status", "sn1"}, wantOut: "online"},
+		{name: "status unknown printer", args: []string{"status", "sn9"}, wantCode: 1},
+		{name: "print", args: []string{"print", "-sn", "sn1", "-content", "hello"}, wantOut: "sn1_"},
+		{name: "print from stdin", stdin: "hello", args: []string{"print", "-sn", "sn1", "-file", "-", "-o", "json"}, wantOut: `"ret": 0`},
+		{name: "print without sn", args: []string{"print", "-content", "hello"}, wantCode: 2},
+		{name: "print ret", args: []string{"print", "-sn", "sn9", "-content", "hello"}, wantCode: 1, wantOut: "1002"},
+		{name: "add", args: []string{"add", "-printer", "sn2#key2#bar", "-printer", "sn1#key1"}, wantCode: 1, wantOut: "已被添加过"},
+		{name: "edit", args: []string{"edit", "-sn", "sn2", "-name", "kitchen"}, wantOut: "true"},
+		{name: "clear-queue", args: []string{"clear-queue", "sn2"}, wantOut: "true"},
+		{name: "stats", args: []string{"stats", "-sn", "sn1,sn2"}, wantOut: "total"},
+		{name: "delete", args: []string{"delete", "sn2"}, wantOut: "sn2"},
+		{name: "bad output", args: []string{"status", "-o", "xml", "sn1"}, wantCode: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runCLI(t, srv, tt.stdin, tt.args...)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d\nstdout: %s\nstderr: %s", code, tt.wantCode, stdout, stderr)
+			}
+			if !strings.Contains(stdout, tt.wantOut) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestRun_OrderState(t *testing.T) {
+	srv := feietest.NewServer()
+	defer srv.Close()
+	srv.AddPrinter(feietest.DefaultUser, "sn1", "key1")
+
+	code, stdout, stderr := runCLI(t, srv, "", "print", "-sn", "sn1", "-content", "x", "-o", "json")
+	if code != 0 {
+		t.Fatalf("print exit code = %d: %s", code, stderr)
+	}
+	var resp struct{ Data string }
+	if err := json.Unmarshal([]byte(stdout), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if code, stdout, _ = runCLI(t, srv, "", "order-state", resp.Data); code != 0 || !strings.Contains(stdout, "true") {
+		t.Errorf("order-state = %d %q", code, stdout)
+	}
+}
+
+func TestRun_Credentials(t *testing.T) {
+	srv := feietest.NewServer()
+	defer srv.Close()
+	srv.AddPrinter(feietest.DefaultUser, "sn1", "key1")
+
+	config := filepath.Join(t.TempDir(), "feie.yaml")
+	content := "user: " + feietest.DefaultUser + "\nukey: " + feietest.DefaultUKey + "\ngateway: " + srv.URL + "\n"
+	if err := os.WriteFile(config, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FEIE_CONFIG", config)
+	if code, stdout, stderr := runCLI(t, nil, "", "status", "sn1"); code != 0 || !strings.Contains(stdout, "online") {
+		t.Fatalf("status with config = %d %q %q", code, stdout, stderr)
+	}
+	t.Setenv("FEIE_UKEY", "wrong")
+	if code, _, _ := runCLI(t, nil, "", "status", "sn1"); code != 1 {
+		t.Errorf("status with FEIE_UKEY overriding config exit code = %d, want 1", code)
+	}
+	t.Setenv("FEIE_CONFIG", "")
+	t.Setenv("FEIE_UKEY", "")
+	if code, _, _ := runCLI(t, nil, "", "status", "-gateway", srv.URL, "sn1"); code != 2 {
+		t.Errorf("status without credentials exit code = %d, want 2", code)
+	}
+}
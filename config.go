@@ -0,0 +1,395 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of the environment variables read by Config.LoadEnv.
+const EnvPrefix = "FEIE_"
+
+// Duration is a time.Duration written as a string such as "30s" in config files.
+type Duration time.Duration
+
+// UnmarshalText parses a duration such as "1m30s".
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(strings.TrimSpace(string(text)))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText formats the duration.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalYAML parses a duration string.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
+// LogConfig is the log section of Config, the client logs nothing unless Path is set.
+type LogConfig struct {
+	Path       string `json:"path" yaml:"path" toml:"path"`                      // 日志目录，写入 Path/feie.log
+	Level      string `json:"level" yaml:"level" toml:"level"`                   // trace, debug, info, notice, warn, error, fatal
+	MaxSize    int    `json:"max_size" yaml:"max_size" toml:"max_size"`          // 单个文件最大 MB
+	MaxBackups int    `json:"max_backups" yaml:"max_backups" toml:"max_backups"` // 保留的旧文件数
+	MaxAge     int    `json:"max_age" yaml:"max_age" toml:"max_age"`             // 旧文件保留天数
+	Compress   bool   `json:"compress" yaml:"compress" toml:"compress"`          // 是否压缩旧文件
+}
+
+// RetryConfig is the retry section of Config, see WithRetry.
+type RetryConfig struct {
+	MaxAttempts int      `json:"max_attempts" yaml:"max_attempts" toml:"max_attempts"`
+	Backoff     Duration `json:"backoff" yaml:"backoff" toml:"backoff"`
+	MaxBackoff  Duration `json:"max_backoff" yaml:"max_backoff" toml:"max_backoff"`
+}
+
+// RateLimitConfig is the rate limit section of Config, see WithRateLimit.
+type RateLimitConfig struct {
+	RPS   float64 `json:"rps" yaml:"rps" toml:"rps"`
+	Burst int     `json:"burst" yaml:"burst" toml:"burst"`
+}
+
+// Config is the client configuration loaded from a YAML, TOML or JSON file and FEIE_* environment variables.
+//
+//	user: xxx
+//	ukey: xxx
+//	timeout: 10s
+//	retry:
+//	  max_attempts: 3
+//	  backoff: 200ms
+//	rate_limit:
+//	  rps: 10
+type Config struct {
+	User             string          `json:"user" yaml:"user" toml:"user"`
+	UKey             string          `json:"ukey" yaml:"ukey" toml:"ukey"`
+	Gateway          string          `json:"gateway" yaml:"gateway" toml:"gateway"`
+	PublicKey        string          `json:"public_key" yaml:"public_key" toml:"public_key"`
+	Timeout          Duration        `json:"timeout" yaml:"timeout" toml:"timeout"`
+	UserAgent        string          `json:"user_agent" yaml:"user_agent" toml:"user_agent"`
+	BatchConcurrency int             `json:"batch_concurrency" yaml:"batch_concurrency" toml:"batch_concurrency"`
+	Log              LogConfig       `json:"log" yaml:"log" toml:"log"`
+	Retry            RetryConfig     `json:"retry" yaml:"retry" toml:"retry"`
+	RateLimit        RateLimitConfig `json:"rate_limit" yaml:"rate_limit" toml:"rate_limit"`
+}
+
+// ConfigError is a Config field that failed to load or validate, Field is the key in the config file.
+type ConfigError struct {
+	Field string
+	Err   error
+}
+
+// Error returns the message of the error.
+func (e *ConfigError) Error() string {
+	return "feie: config " + e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// LoadConfig loads the config file, when path is not empty, then applies the FEIE_* environment variables.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if path != "" {
+		var err error
+		if cfg, err = LoadConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.LoadEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadConfigFile loads a config file, the format follows the extension: .yaml, .yml, .toml or .json.
+func LoadConfigFile(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(content, cfg)
+	case ".toml":
+		var md toml.MetaData
+		if md, err = toml.NewDecoder(bytes.NewReader(content)).Decode(cfg); err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown field %s", md.Undecoded()[0])
+		}
+	case ".json":
+		err = decodeJSONConfig(content, cfg)
+	default:
+		return nil, fmt.Errorf("feie: config %s: unsupported format %q", path, ext)
+	}
+	var cfgErr *ConfigError
+	if errors.As(err, &cfgErr) {
+		cfgErr.Err = fmt.Errorf("%s: %w", path, cfgErr.Err)
+		return nil, cfgErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("feie: config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// decodeJSONConfig decodes a JSON config strictly like the YAML and TOML ones, an unknown or
+// mistyped key is a *ConfigError naming it. It uses encoding/json, whose errors carry the key.
+func decodeJSONConfig(content []byte, cfg *Config) error {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(cfg)
+	if err == nil {
+		if dec.More() {
+			return errors.New("unexpected content after the config object")
+		}
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &ConfigError{Field: typeErr.Field, Err: fmt.Errorf("cannot be a JSON %s", typeErr.Value)}
+	}
+	if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+		if unquoted, uerr := strconv.Unquote(field); uerr == nil {
+			field = unquoted
+		}
+		return &ConfigError{Field: field, Err: errors.New("unknown field")}
+	}
+	return err
+}
+
+// LoadEnv overrides the config with the FEIE_* environment variables that are set:
+// FEIE_USER, FEIE_UKEY, FEIE_GATEWAY, FEIE_PUBLIC_KEY, FEIE_TIMEOUT, FEIE_USER_AGENT,
+// FEIE_BATCH_CONCURRENCY, FEIE_LOG_PATH, FEIE_LOG_LEVEL, FEIE_LOG_MAX_SIZE, FEIE_LOG_MAX_BACKUPS,
+// FEIE_LOG_MAX_AGE, FEIE_LOG_COMPRESS, FEIE_RETRY_MAX_ATTEMPTS,
+// FEIE_RETRY_BACKOFF, FEIE_RETRY_MAX_BACKOFF, FEIE_RATE_LIMIT_RPS and FEIE_RATE_LIMIT_BURST.
+func (c *Config) LoadEnv() error {
+	for _, f := range []struct {
+		name string
+		set  func(v string) error
+	}{
+		{"USER", setString(&c.User)},
+		{"UKEY", setString(&c.UKey)},
+		{"GATEWAY", setString(&c.Gateway)},
+		{"PUBLIC_KEY", setString(&c.PublicKey)},
+		{"TIMEOUT", setDuration(&c.Timeout)},
+		{"USER_AGENT", setString(&c.UserAgent)},
+		{"BATCH_CONCURRENCY", setInt(&c.BatchConcurrency)},
+		{"LOG_PATH", setString(&c.Log.Path)},
+		{"LOG_LEVEL", setString(&c.Log.Level)},
+		{"LOG_MAX_SIZE", setInt(&c.Log.MaxSize)},
+		{"LOG_MAX_BACKUPS", setInt(&c.Log.MaxBackups)},
+		{"LOG_MAX_AGE", setInt(&c.Log.MaxAge)},
+		{"LOG_COMPRESS", setBool(&c.Log.Compress)},
+		{"RETRY_MAX_ATTEMPTS", setInt(&c.Retry.MaxAttempts)},
+		{"RETRY_BACKOFF", setDuration(&c.Retry.Backoff)},
+		{"RETRY_MAX_BACKOFF", setDuration(&c.Retry.MaxBackoff)},
+		{"RATE_LIMIT_RPS", setFloat(&c.RateLimit.RPS)},
+		{"RATE_LIMIT_BURST", setInt(&c.RateLimit.Burst)},
+	} {
+		v, ok := os.LookupEnv(EnvPrefix + f.name)
+		if !ok {
+			continue
+		}
+		if err := f.set(v); err != nil {
+			return &ConfigError{Field: EnvPrefix + f.name, Err: err}
+		}
+	}
+	return nil
+}
+
+func setString(p *string) func(string) error {
+	return func(v string) error {
+		*p = strings.TrimSpace(v)
+		return nil
+	}
+}
+
+func setInt(p *int) func(string) error {
+	return func(v string) (err error) {
+		*p, err = strconv.Atoi(strings.TrimSpace(v))
+		return
+	}
+}
+
+func setFloat(p *float64) func(string) error {
+	return func(v string) (err error) {
+		*p, err = strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return
+	}
+}
+
+func setBool(p *bool) func(string) error {
+	return func(v string) (err error) {
+		*p, err = strconv.ParseBool(strings.TrimSpace(v))
+		return
+	}
+}
+
+func setDuration(p *Duration) func(string) error {
+	return func(v string) error {
+		return p.UnmarshalText([]byte(v))
+	}
+}
+
+// logLevels maps the log level names of Config to hlog levels.
+var logLevels = map[string]hlog.Level{
+	"trace":  hlog.LevelTrace,
+	"debug":  hlog.LevelDebug,
+	"info":   hlog.LevelInfo,
+	"notice": hlog.LevelNotice,
+	"warn":   hlog.LevelWarn,
+	"error":  hlog.LevelError,
+	"fatal":  hlog.LevelFatal,
+}
+
+// Validate checks the config, the error is a *ConfigError naming the first offending field.
+func (c *Config) Validate() error {
+	invalid := func(field, format string, args ...interface{}) error {
+		return &ConfigError{Field: field, Err: fmt.Errorf(format, args...)}
+	}
+	if strings.TrimSpace(c.User) == "" {
+		return invalid("user", "is required")
+	}
+	if strings.TrimSpace(c.UKey) == "" {
+		return invalid("ukey", "is required")
+	}
+	if c.Gateway != "" {
+		u, err := url.Parse(c.Gateway)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return invalid("gateway", "%q is not an http or https URL", c.Gateway)
+		}
+	}
+	if c.PublicKey != "" {
+		if _, err := base64.StdEncoding.DecodeString(c.PublicKey); err != nil {
+			return invalid("public_key", "is not base64: %v", err)
+		}
+	}
+	if c.Timeout < 0 {
+		return invalid("timeout", "must not be negative")
+	}
+	if c.BatchConcurrency < 0 {
+		return invalid("batch_concurrency", "must not be negative")
+	}
+	if _, ok := logLevels[strings.ToLower(c.Log.Level)]; c.Log.Level != "" && !ok {
+		return invalid("log.level", "unknown level %q", c.Log.Level)
+	}
+	if c.Log.MaxSize < 0 {
+		return invalid("log.max_size", "must not be negative")
+	}
+	if c.Log.MaxBackups < 0 {
+		return invalid("log.max_backups", "must not be negative")
+	}
+	if c.Log.MaxAge < 0 {
+		return invalid("log.max_age", "must not be negative")
+	}
+	if c.Retry.MaxAttempts < 0 {
+		return invalid("retry.max_attempts", "must not be negative")
+	}
+	if c.Retry.Backoff < 0 {
+		return invalid("retry.backoff", "must not be negative")
+	}
+	if c.Retry.MaxBackoff < 0 || (c.Retry.MaxBackoff > 0 && c.Retry.MaxBackoff < c.Retry.Backoff) {
+		return invalid("retry.max_backoff", "must not be negative or less than retry.backoff")
+	}
+	if c.RateLimit.RPS < 0 {
+		return invalid("rate_limit.rps", "must not be negative")
+	}
+	if c.RateLimit.Burst < 0 {
+		return invalid("rate_limit.burst", "must not be negative")
+	}
+	return nil
+}
+
+// Options validates the config and returns the options it sets, the zero fields keep the defaults of New.
+func (c *Config) Options() ([]Option, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	opts := []Option{WithUser(strings.TrimSpace(c.User)), WithUserKey(strings.TrimSpace(c.UKey))}
+	if c.Gateway != "" {
+		opts = append(opts, WithGateway(c.Gateway))
+	}
+	if c.PublicKey != "" {
+		opts = append(opts, WithPublicKey(c.PublicKey))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, WithTimeOut(time.Duration(c.Timeout)))
+	}
+	if c.UserAgent != "" {
+		opts = append(opts, WithUserAgent([]byte(c.UserAgent)))
+	}
+	if c.BatchConcurrency > 0 {
+		opts = append(opts, WithBatchConcurrency(c.BatchConcurrency))
+	}
+	if c.Log.Path != "" {
+		opts = append(opts, WithFileLog(LogFileConfig{
+			Filename:   filepath.Join(c.Log.Path, "feie.log"),
+			MaxSize:    c.Log.MaxSize,
+			MaxBackups: c.Log.MaxBackups,
+			MaxAge:     c.Log.MaxAge,
+			Compress:   c.Log.Compress,
+		}))
+	}
+	if c.Log.Level != "" {
+		opts = append(opts, WithLevel(Level(logLevels[strings.ToLower(c.Log.Level)])))
+	}
+	if c.Retry.MaxAttempts > 1 {
+		opts = append(opts, WithRetry(c.Retry.MaxAttempts, time.Duration(c.Retry.Backoff), time.Duration(c.Retry.MaxBackoff)))
+	}
+	if c.RateLimit.RPS > 0 {
+		opts = append(opts, WithRateLimit(c.RateLimit.RPS, c.RateLimit.Burst))
+	}
+	return opts, nil
+}
+
+// NewFromConfig validates the config and returns a client built from it, opts are applied after it.
+func NewFromConfig(ctx context.Context, cfg *Config, opts ...Option) (*Client, error) {
+	cfgOpts, err := cfg.Options()
+	if err != nil {
+		return nil, err
+	}
+	return New(ctx, append(cfgOpts, opts...)...), nil
+}
@@ -0,0 +1,196 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	want := &Config{
+		User:    "user",
+		UKey:    "ukey",
+		Timeout: Duration(10 * time.Second),
+		Log:     LogConfig{Level: "warn"},
+		Retry:   RetryConfig{MaxAttempts: 3, Backoff: Duration(200 * time.Millisecond), MaxBackoff: Duration(time.Second)},
+		RateLimit: RateLimitConfig{
+			RPS:   5,
+			Burst: 2,
+		},
+	}
+	tests := []struct {
+		name      string
+		content   string
+		wantErr   bool
+		wantField string
+	}{
+		{
+			name:    "feie.yaml",
+			content: "user: user\nukey: ukey\ntimeout: 10s\nlog:\n  level: warn\nretry:\n  max_attempts: 3\n  backoff: 200ms\n  max_backoff: 1s\nrate_limit:\n  rps: 5\n  burst: 2\n",
+		},
+		{
+			name:    "feie.toml",
+			content: "user = \"user\"\nukey = \"ukey\"\ntimeout = \"10s\"\n[log]\nlevel = \"warn\"\n[retry]\nmax_attempts = 3\nbackoff = \"200ms\"\nmax_backoff = \"1s\"\n[rate_limit]\nrps = 5.0\nburst = 2\n",
+		},
+		{
+			name:    "feie.json",
+			content: `{"user":"user","ukey":"ukey","timeout":"10s","log":{"level":"warn"},"retry":{"max_attempts":3,"backoff":"200ms","max_backoff":"1s"},"rate_limit":{"rps":5,"burst":2}}`,
+		},
+		{name: "unknown.yaml", content: "user: user\nukye: typo\n", wantErr: true},
+		{name: "unknown.toml", content: "user = \"user\"\nukye = \"typo\"\n", wantErr: true},
+		{name: "bad.yaml", content: "timeout: soon\n", wantErr: true},
+		{name: "unknown.json", content: `{"user":"user","time_out":"10s"}`, wantErr: true, wantField: "time_out"},
+		{name: "mistyped.json", content: `{"batch_concurrency":"4"}`, wantErr: true, wantField: "batch_concurrency"},
+		{name: "trailing.json", content: `{"user":"user"} {"ukey":"ukey"}`, wantErr: true},
+		{name: "feie.ini", content: "user=user", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadConfigFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var cfgErr *ConfigError
+			if tt.wantField != "" && (!errors.As(err, &cfgErr) || cfgErr.Field != tt.wantField) {
+				t.Errorf("LoadConfigFile() error = %v, want a *ConfigError on %s", err, tt.wantField)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadConfigFile() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestConfig_LoadEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feie.yaml")
+	if err := os.WriteFile(path, []byte("user: file\nukey: file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FEIE_UKEY", "env")
+	t.Setenv("FEIE_RETRY_BACKOFF", "1s")
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.User != "file" || cfg.UKey != "env" || cfg.Retry.Backoff != Duration(time.Second) {
+		t.Errorf("LoadConfig() = %+v", cfg)
+	}
+
+	t.Setenv("FEIE_RATE_LIMIT_BURST", "many")
+	_, err = LoadConfig(path)
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) || cfgErr.Field != "FEIE_RATE_LIMIT_BURST" {
+		t.Errorf("LoadConfig() error = %v, want a ConfigError on FEIE_RATE_LIMIT_BURST", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() *Config { return &Config{User: "user", UKey: "ukey"} }
+	tests := []struct {
+		field  string
+		modify func(c *Config)
+	}{
+		{"", func(c *Config) {}},
+		{"user", func(c *Config) { c.User = " " }},
+		{"ukey", func(c *Config) { c.UKey = "" }},
+		{"gateway", func(c *Config) { c.Gateway = "api.feieyun.cn" }},
+		{"public_key", func(c *Config) { c.PublicKey = "not base64!" }},
+		{"timeout", func(c *Config) { c.Timeout = -1 }},
+		{"log.level", func(c *Config) { c.Log.Level = "verbose" }},
+		{"log.max_size", func(c *Config) { c.Log.MaxSize = -1 }},
+		{"log.max_age", func(c *Config) { c.Log.MaxAge = -1 }},
+		{"retry.max_attempts", func(c *Config) { c.Retry.MaxAttempts = -1 }},
+		{"retry.max_backoff", func(c *Config) { c.Retry.Backoff, c.Retry.MaxBackoff = 2, 1 }},
+		{"rate_limit.rps", func(c *Config) { c.RateLimit.RPS = -1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			var cfgErr *ConfigError
+			switch {
+			case tt.field == "" && err != nil:
+				t.Errorf("Validate() error = %v", err)
+			case tt.field != "" && (!errors.As(err, &cfgErr) || cfgErr.Field != tt.field):
+				t.Errorf("Validate() error = %v, want a ConfigError on %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestNewFromConfig_Retry(t *testing.T) {
+	var calls int32
+	transport := TransportFunc(func(ctx context.Context, gateway string, form map[string]string) ([]byte, error) {
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			return nil, errors.New("connection reset")
+		}
+		return []byte(`{"ret":0,"msg":"ok","data":"在线，工作状态正常。"}`), nil
+	})
+	cfg := &Config{User: "user", UKey: "ukey", Retry: RetryConfig{MaxAttempts: 3, Backoff: Duration(time.Millisecond)}}
+	c, err := NewFromConfig(context.Background(), cfg, WithTransport(transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.OpenQueryPrinterStatus(context.Background(), &QueryPrinterStatusReq{SN: "sn1"})
+	if err != nil || resp.Status() != PrinterStatusOnline || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("OpenQueryPrinterStatus() = %+v, %v after %d calls", resp, err, calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	if _, err = c.OpenPrintMsg(context.Background(), &PrintMsgReq{SN: "sn1", Content: "x"}); err == nil || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("OpenPrintMsg() error = %v after %d calls, want one failed call", err, calls)
+	}
+
+	if _, err = NewFromConfig(context.Background(), &Config{User: "user"}); err == nil {
+		t.Error("NewFromConfig() without ukey succeeded")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(50, 2)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("4 requests at 50/s with burst 2 took %v, want at least 40ms", elapsed)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := newRateLimiter(0.001, 1).wait(ctx); err != nil {
+		t.Errorf("first request within burst error = %v", err)
+	}
+	if err := (*rateLimiter)(nil).wait(ctx); err != nil {
+		t.Errorf("nil limiter error = %v", err)
+	}
+}
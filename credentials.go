@@ -0,0 +1,183 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// CredentialsProvider returns the credentials of every request, see WithCredentialsProvider.
+// It is called once per request, so a request never mixes the user and UKEY of two versions.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialsProviderFunc adapts a func to CredentialsProvider.
+type CredentialsProviderFunc func(ctx context.Context) (Credentials, error)
+
+// Credentials calls f.
+func (f CredentialsProviderFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// StaticCredentials returns a provider of fixed credentials.
+func StaticCredentials(user, ukey string) CredentialsProvider {
+	creds := Credentials{User: user, UKey: ukey}
+	return CredentialsProviderFunc(func(context.Context) (Credentials, error) {
+		return creds, creds.Validate()
+	})
+}
+
+// EnvCredentials returns a provider reading FEIE_USER and FEIE_UKEY on every request.
+func EnvCredentials() CredentialsProvider {
+	return CredentialsProviderFunc(func(context.Context) (Credentials, error) {
+		creds := Credentials{
+			User: strings.TrimSpace(os.Getenv(EnvPrefix + "USER")),
+			UKey: strings.TrimSpace(os.Getenv(EnvPrefix + "UKEY")),
+		}
+		return creds, creds.Validate()
+	})
+}
+
+// FileCredentialsOption is the option of FileCredentials.
+type FileCredentialsOption func(p *FileCredentials)
+
+// WithFileCredentialsErrorHandler calls fn when reloading the file fails, the last UKEY read stays in use.
+func WithFileCredentialsErrorHandler(fn func(err error)) FileCredentialsOption {
+	return func(p *FileCredentials) {
+		p.onError = fn
+	}
+}
+
+// WithFileCredentialsReload calls fn after the UKEY is reloaded.
+func WithFileCredentialsReload(fn func()) FileCredentialsOption {
+	return func(p *FileCredentials) {
+		p.onReload = fn
+	}
+}
+
+// FileCredentials provides the UKEY stored in a file, such as a mounted secret, and reloads it when
+// the file changes. The directory is watched, so files replaced by rename or symlink swap are seen.
+type FileCredentials struct {
+	user     string
+	path     string
+	creds    atomic.Value // Credentials
+	watcher  *fsnotify.Watcher
+	onError  func(err error)
+	onReload func()
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+// NewFileCredentials reads the UKEY of user from path and watches the file until Close.
+func NewFileCredentials(user, path string, opts ...FileCredentialsOption) (*FileCredentials, error) {
+	p := &FileCredentials{user: user, path: filepath.Clean(path), done: make(chan struct{})}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err = watcher.Add(filepath.Dir(p.path)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	p.watcher = watcher
+	p.wg.Add(1)
+	go p.watch()
+	return p, nil
+}
+
+// Credentials implements CredentialsProvider.
+func (p *FileCredentials) Credentials(context.Context) (Credentials, error) {
+	return p.creds.Load().(Credentials), nil
+}
+
+// Reload reads the file again, the previous UKEY is kept when the file is empty or unreadable.
+func (p *FileCredentials) Reload() error {
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	creds := Credentials{User: p.user, UKey: strings.TrimSpace(string(content))}
+	if err = creds.Validate(); err != nil {
+		return err
+	}
+	p.creds.Store(creds)
+	return nil
+}
+
+// watch reloads the file on every change in its directory until Close.
+func (p *FileCredentials) watch() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.done:
+			return
+		case event, ok := <-p.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			old := p.creds.Load().(Credentials)
+			if err := p.Reload(); err != nil {
+				// a file being replaced may be missing or empty for a moment, the next event reloads it.
+				if p.onError != nil && filepath.Clean(event.Name) == p.path {
+					p.onError(err)
+				}
+				continue
+			}
+			if p.onReload != nil && p.creds.Load().(Credentials) != old {
+				p.onReload()
+			}
+		case err, ok := <-p.watcher.Errors:
+			if !ok {
+				return
+			}
+			if p.onError != nil {
+				p.onError(err)
+			}
+		}
+	}
+}
+
+// Close stops watching the file.
+func (p *FileCredentials) Close() error {
+	var err error
+	p.once.Do(func() {
+		close(p.done)
+		err = p.watcher.Close()
+		p.wg.Wait()
+	})
+	return err
+}
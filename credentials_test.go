@@ -0,0 +1,111 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticAndEnvCredentials(t *testing.T) {
+	ctx := context.Background()
+	if creds, err := StaticCredentials("user", "ukey").Credentials(ctx); err != nil || creds.UKey != "ukey" {
+		t.Errorf("StaticCredentials() = %+v, %v", creds, err)
+	}
+	if _, err := StaticCredentials("user", "").Credentials(ctx); err == nil {
+		t.Error("StaticCredentials() without ukey succeeded")
+	}
+	t.Setenv("FEIE_USER", "env-user")
+	t.Setenv("FEIE_UKEY", "env-ukey")
+	provider := EnvCredentials()
+	if creds, err := provider.Credentials(ctx); err != nil || creds != (Credentials{User: "env-user", UKey: "env-ukey"}) {
+		t.Errorf("EnvCredentials() = %+v, %v", creds, err)
+	}
+	t.Setenv("FEIE_UKEY", "rotated")
+	if creds, _ := provider.Credentials(ctx); creds.UKey != "rotated" {
+		t.Errorf("EnvCredentials() after change = %+v", creds)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ukey")
+	if err := os.WriteFile(path, []byte("ukey\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := make(chan struct{}, 8)
+	provider, err := NewFileCredentials("user", path, WithFileCredentialsReload(func() { reloaded <- struct{}{} }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Close()
+
+	srv := newTenantGateway(t, map[string]string{"user": "ukey"})
+	c := New(ctx, WithGateway(srv.URL), WithUser("other"), WithUserKey("other"), WithCredentialsProvider(provider))
+	if resp, err := c.OpenQueryPrinterStatus(ctx, &QueryPrinterStatusReq{SN: "sn"}); err != nil || resp.Ret != 0 {
+		t.Fatalf("OpenQueryPrinterStatus() = %+v, %v", resp, err)
+	}
+
+	waitReload := func(want string) {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for {
+			if creds, _ := provider.Credentials(ctx); creds.UKey == want {
+				return
+			}
+			select {
+			case <-reloaded:
+			case <-time.After(20 * time.Millisecond):
+			case <-deadline:
+				t.Fatalf("ukey not reloaded to %q", want)
+			}
+		}
+	}
+
+	// rotate by rename, as secret mounts do
+	tmp := filepath.Join(dir, "ukey.tmp")
+	if err = os.WriteFile(tmp, []byte("rotated"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	waitReload("rotated")
+
+	// an empty file keeps the last ukey
+	if err = os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if creds, _ := provider.Credentials(ctx); creds.UKey != "rotated" {
+		t.Errorf("empty file replaced the ukey with %q", creds.UKey)
+	}
+	if err = os.WriteFile(path, []byte("third"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitReload("third")
+
+	if _, err = NewFileCredentials("user", filepath.Join(dir, "missing")); err == nil {
+		t.Error("NewFileCredentials() of a missing file succeeded")
+	}
+}
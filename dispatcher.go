@@ -0,0 +1,344 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDispatcherClosed is returned when a job is submitted after Close.
+	ErrDispatcherClosed = errors.New("feie: dispatcher is closed")
+
+	// ErrDispatchQueueFull is returned by TrySubmit when the queue of the printer is full.
+	ErrDispatchQueueFull = errors.New("feie: dispatch queue is full")
+)
+
+// DispatchResult is the outcome of a job submitted to Dispatcher.
+type DispatchResult struct {
+	SN      string        `json:"sn"`
+	OrderID string        `json:"orderId,omitempty"`
+	Err     error         `json:"-"`
+	Wait    time.Duration `json:"wait"`
+	Latency time.Duration `json:"latency"`
+}
+
+// DispatchStats is the statistics of the queue of one printer.
+type DispatchStats struct {
+	SN          string        `json:"sn"`
+	Queued      int           `json:"queued"`
+	InFlight    bool          `json:"inFlight"`
+	Completed   uint64        `json:"completed"`
+	Failed      uint64        `json:"failed"`
+	LastLatency time.Duration `json:"lastLatency"`
+	AvgLatency  time.Duration `json:"avgLatency"`
+	AvgWait     time.Duration `json:"avgWait"`
+}
+
+// DispatcherOption is the option of Dispatcher.
+type DispatcherOption func(d *Dispatcher)
+
+// WithDispatchQueueDepth sets the number of jobs waiting per printer before Submit blocks, default 16.
+func WithDispatchQueueDepth(n int) DispatcherOption {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.depth = n
+		}
+	}
+}
+
+// WithDispatchIdleTimeout sets how long the lane of a printer is kept without jobs, default 1 minute.
+// An idle lane is removed with its statistics, and created again by the next job of the printer.
+func WithDispatchIdleTimeout(idle time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		if idle > 0 {
+			d.idle = idle
+		}
+	}
+}
+
+// dispatchItem is a job waiting in a lane.
+type dispatchItem struct {
+	ctx       context.Context
+	job       *Job
+	submitted time.Time
+	result    chan DispatchResult
+}
+
+// dispatchLane is the queue and worker of one printer.
+type dispatchLane struct {
+	items chan *dispatchItem
+	users int // submitters holding the lane, guarded by Dispatcher.lanesMu
+	mu    sync.Mutex
+	stats DispatchStats
+	wait  time.Duration
+	total time.Duration
+}
+
+// Dispatcher sends the jobs of a printer one at a time in submission order, while the
+// printers are served in parallel. Each printer has a bounded queue: Submit blocks when it
+// is full and TrySubmit fails with ErrDispatchQueueFull.
+type Dispatcher struct {
+	client *Client
+	depth  int
+	idle   time.Duration
+
+	// mu is held for reading while a job is sent to its lane, so Close never closes a lane
+	// during a send. stop wakes the submissions blocked on a full lane first.
+	mu       sync.RWMutex
+	closed   bool
+	stop     chan struct{}
+	stopOnce sync.Once
+	lanesMu  sync.Mutex
+	lanes    map[string]*dispatchLane
+	wg       sync.WaitGroup
+}
+
+// NewDispatcher returns a new Dispatcher.
+func NewDispatcher(c *Client, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{client: c, depth: 16, idle: time.Minute, stop: make(chan struct{}), lanes: make(map[string]*dispatchLane)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// PrintMsg submits the request and waits for its result.
+func (d *Dispatcher) PrintMsg(ctx context.Context, req *PrintMsgReq) (string, error) {
+	return d.wait(ctx, &Job{Kind: JobPrintMsg, Msg: req})
+}
+
+// PrintLabelMsg submits the request and waits for its result.
+func (d *Dispatcher) PrintLabelMsg(ctx context.Context, req *PrintLabelMsgReq) (string, error) {
+	return d.wait(ctx, &Job{Kind: JobPrintLabelMsg, Label: req})
+}
+
+// wait submits the job and waits for its order ID.
+func (d *Dispatcher) wait(ctx context.Context, job *Job) (string, error) {
+	result, err := d.Submit(ctx, job)
+	if err != nil {
+		return "", err
+	}
+	select {
+	case r := <-result:
+		return r.OrderID, r.Err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Submit queues the job behind the jobs already submitted to the same printer, blocking while
+// the queue is full. The result is sent once on the returned channel. ctx also bounds the
+// request itself, a job whose ctx is done before its turn fails with ctx.Err().
+func (d *Dispatcher) Submit(ctx context.Context, job *Job) (<-chan DispatchResult, error) {
+	return d.submit(ctx, job, true)
+}
+
+// TrySubmit is Submit failing with ErrDispatchQueueFull instead of blocking.
+func (d *Dispatcher) TrySubmit(ctx context.Context, job *Job) (<-chan DispatchResult, error) {
+	return d.submit(ctx, job, false)
+}
+
+// submit queues the job into the lane of its printer.
+func (d *Dispatcher) submit(ctx context.Context, job *Job, block bool) (<-chan DispatchResult, error) {
+	if err := job.validate(); err != nil {
+		return nil, err
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return nil, ErrDispatcherClosed
+	}
+	var (
+		lane = d.acquire(job.SN())
+		item = &dispatchItem{ctx: ctx, job: job, submitted: time.Now(), result: make(chan DispatchResult, 1)}
+	)
+	defer d.release(lane)
+	if !block {
+		select {
+		case lane.items <- item:
+			return item.result, nil
+		default:
+			return nil, ErrDispatchQueueFull
+		}
+	}
+	select {
+	case lane.items <- item:
+		return item.result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-d.stop:
+		return nil, ErrDispatcherClosed
+	}
+}
+
+// acquire returns the lane of the printer sn, starting its worker on first use.
+// The lane is not removed before release is called.
+func (d *Dispatcher) acquire(sn string) *dispatchLane {
+	d.lanesMu.Lock()
+	defer d.lanesMu.Unlock()
+	lane, ok := d.lanes[sn]
+	if !ok {
+		lane = &dispatchLane{items: make(chan *dispatchItem, d.depth), stats: DispatchStats{SN: sn}}
+		d.lanes[sn] = lane
+		d.wg.Add(1)
+		go d.work(lane)
+	}
+	lane.users++
+	return lane
+}
+
+// release releases a lane returned by acquire.
+func (d *Dispatcher) release(lane *dispatchLane) {
+	d.lanesMu.Lock()
+	lane.users--
+	d.lanesMu.Unlock()
+}
+
+// reap removes the lane when nobody holds it and its queue is empty.
+func (d *Dispatcher) reap(lane *dispatchLane) bool {
+	d.lanesMu.Lock()
+	defer d.lanesMu.Unlock()
+	if lane.users > 0 || len(lane.items) > 0 || d.lanes[lane.stats.SN] != lane {
+		return false
+	}
+	delete(d.lanes, lane.stats.SN)
+	return true
+}
+
+// work sends the jobs of a lane one by one, and stops once the lane is idle and reaped.
+func (d *Dispatcher) work(lane *dispatchLane) {
+	defer d.wg.Done()
+	for {
+		timer := time.NewTimer(d.idle)
+		select {
+		case item, ok := <-lane.items:
+			timer.Stop()
+			if !ok {
+				return
+			}
+			d.send(lane, item)
+		case <-timer.C:
+			if d.reap(lane) {
+				return
+			}
+		}
+	}
+}
+
+// send sends the job of item and records its result.
+func (d *Dispatcher) send(lane *dispatchLane, item *dispatchItem) {
+	var (
+		start   = time.Now()
+		orderID string
+		err     = item.ctx.Err()
+	)
+	lane.mu.Lock()
+	lane.stats.InFlight = true
+	lane.mu.Unlock()
+	if err == nil {
+		orderID, err = d.client.sendJob(item.ctx, item.job)
+	}
+	var (
+		end    = time.Now()
+		result = DispatchResult{
+			SN:      lane.stats.SN,
+			OrderID: orderID,
+			Err:     err,
+			Wait:    start.Sub(item.submitted),
+			Latency: end.Sub(start),
+		}
+	)
+	lane.mu.Lock()
+	lane.stats.InFlight = false
+	if err != nil {
+		lane.stats.Failed++
+	} else {
+		lane.stats.Completed++
+	}
+	lane.stats.LastLatency = result.Latency
+	lane.wait += result.Wait
+	lane.total += result.Latency
+	lane.mu.Unlock()
+	item.result <- result
+}
+
+// Stats returns the statistics of every printer with a lane, sorted by SN.
+func (d *Dispatcher) Stats() []DispatchStats {
+	d.lanesMu.Lock()
+	lanes := make([]*dispatchLane, 0, len(d.lanes))
+	for _, lane := range d.lanes {
+		lanes = append(lanes, lane)
+	}
+	d.lanesMu.Unlock()
+
+	stats := make([]DispatchStats, 0, len(lanes))
+	for _, lane := range lanes {
+		lane.mu.Lock()
+		s := lane.stats
+		if n := time.Duration(s.Completed + s.Failed); n > 0 {
+			s.AvgLatency = lane.total / n
+			s.AvgWait = lane.wait / n
+		}
+		lane.mu.Unlock()
+		s.Queued = len(lane.items)
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].SN < stats[j].SN
+	})
+	return stats
+}
+
+// Close stops accepting jobs and waits until the queued jobs are sent or ctx is done.
+// The submissions blocked on a full lane fail with ErrDispatcherClosed.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+	})
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return ErrDispatcherClosed
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	d.lanesMu.Lock()
+	for _, lane := range d.lanes {
+		close(lane.items)
+	}
+	d.lanesMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
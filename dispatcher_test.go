@@ -0,0 +1,200 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatcher(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received = map[string][]string{}
+		active   int32
+		peak     int32
+		release  = make(chan struct{})
+	)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		if form[SNField] == "blocked" {
+			<-release
+		}
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		mu.Lock()
+		received[form[SNField]] = append(received[form[SNField]], form[ContentField])
+		mu.Unlock()
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_" + form[ContentField]}
+	})
+	var (
+		ctx = context.Background()
+		c   = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		d   = NewDispatcher(c, WithDispatchQueueDepth(2))
+		wg  sync.WaitGroup
+	)
+	for _, sn := range []string{"sn1", "sn2", "sn3"} {
+		wg.Add(1)
+		go func(sn string) {
+			defer wg.Done()
+			var results []<-chan DispatchResult
+			for i := 0; i < 10; i++ {
+				result, err := d.Submit(ctx, &Job{Kind: JobPrintMsg, Msg: &PrintMsgReq{SN: sn, Content: strconv.Itoa(i)}})
+				if err != nil {
+					t.Errorf("Submit() error = %v", err)
+					return
+				}
+				results = append(results, result)
+			}
+			for i, result := range results {
+				if r := <-result; r.Err != nil || r.OrderID != sn+"_"+strconv.Itoa(i) {
+					t.Errorf("result %d = %+v", i, r)
+				}
+			}
+		}(sn)
+	}
+	wg.Wait()
+
+	for sn, contents := range received {
+		for i, content := range contents {
+			if content != strconv.Itoa(i) {
+				t.Errorf("printer %s received %v, want submission order", sn, contents)
+				break
+			}
+		}
+	}
+	if peak < 2 {
+		t.Errorf("peak concurrency = %d, want printers served in parallel", peak)
+	}
+	for _, s := range d.Stats() {
+		if s.Completed != 10 || s.Failed != 0 || s.Queued != 0 {
+			t.Errorf("Stats() = %+v", s)
+		}
+	}
+
+	inFlight := func(sn string) bool {
+		for _, s := range d.Stats() {
+			if s.SN == sn {
+				return s.InFlight
+			}
+		}
+		return false
+	}
+	// one job in flight plus a queue depth of 2
+	for i := 0; i < 3; i++ {
+		if _, err := d.Submit(ctx, &Job{Kind: JobPrintMsg, Msg: &PrintMsgReq{SN: "blocked", Content: strconv.Itoa(i)}}); err != nil {
+			t.Fatal(err)
+		}
+		for i == 0 && !inFlight("blocked") {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if _, err := d.TrySubmit(ctx, &Job{Kind: JobPrintMsg, Msg: &PrintMsgReq{SN: "blocked"}}); err != ErrDispatchQueueFull {
+		t.Errorf("TrySubmit() error = %v, want %v", err, ErrDispatchQueueFull)
+	}
+	close(release)
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Submit(ctx, &Job{Kind: JobPrintMsg, Msg: &PrintMsgReq{SN: "sn1"}}); err != ErrDispatcherClosed {
+		t.Errorf("Submit() after Close error = %v, want %v", err, ErrDispatcherClosed)
+	}
+}
+
+func TestDispatcher_IdleLanes(t *testing.T) {
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_1"}
+	})
+	var (
+		ctx = context.Background()
+		c   = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		d   = NewDispatcher(c, WithDispatchIdleTimeout(100*time.Millisecond))
+	)
+	defer d.Close(ctx)
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 5; i++ {
+			sn := "sn" + strconv.Itoa(round*5+i)
+			if id, err := d.PrintMsg(ctx, &PrintMsgReq{SN: sn, Content: "ticket"}); err != nil || id != sn+"_1" {
+				t.Fatalf("PrintMsg(%s) = %q, %v", sn, id, err)
+			}
+		}
+		if n := len(d.Stats()); n != 5 {
+			t.Errorf("round %d lanes = %d, want 5", round, n)
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for len(d.Stats()) > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if n := len(d.Stats()); n != 0 {
+			t.Fatalf("round %d lanes after idle = %d, want 0", round, n)
+		}
+	}
+}
+
+func TestDispatcher_CloseBlockedSubmit(t *testing.T) {
+	release := make(chan struct{})
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		<-release
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_1"}
+	})
+	defer close(release)
+	var (
+		ctx = context.Background()
+		c   = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		d   = NewDispatcher(c, WithDispatchQueueDepth(1))
+	)
+	// one job in flight and one queued, the third submission blocks on the full lane
+	for i := 0; i < 2; i++ {
+		if _, err := d.Submit(ctx, &Job{Kind: JobPrintMsg, Msg: &PrintMsgReq{SN: "sn1", Content: "ticket"}}); err != nil {
+			t.Fatal(err)
+		}
+		for i == 0 && len(d.Stats()) > 0 && !d.Stats()[0].InFlight {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := d.Submit(ctx, &Job{Kind: JobPrintMsg, Msg: &PrintMsgReq{SN: "sn1", Content: "ticket"}})
+		blocked <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	closeCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := d.Close(closeCtx); err != context.DeadlineExceeded {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close() took %v, want it to honor its deadline", elapsed)
+	}
+	if err := <-blocked; err != ErrDispatcherClosed {
+		t.Errorf("blocked Submit() error = %v, want %v", err, ErrDispatcherClosed)
+	}
+}
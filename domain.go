@@ -19,8 +19,11 @@
 package feie
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cloudwego/hertz/pkg/app/client"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/cloudwego/hertz/pkg/protocol"
 	"github.com/houseme/gocrypto"
@@ -36,20 +39,43 @@ type options struct {
 	UserAgent []byte
 	DataType  gocrypto.Encode // 数据类型
 	HashType  gocrypto.Hash   // Hash类型
-	LogPath   string          // 日志路径
-	Level     Level
+	LogPath   string          // 日志目录，非空时写入 LogPath/feie.log
+	Level     Level           // SDK 创建的日志的级别
+	Logger    Logger          // 调用方提供的日志，默认丢弃
+	LogFile   *LogFileConfig  // 滚动日志文件，默认不写文件
+
+	BatchConcurrency int // 批量操作并发数
+
+	IdempotencyStore IdempotencyStore // 幂等键存储
+	IdempotencyTTL   time.Duration    // 幂等键有效期
+
+	Transport Transport // 自定义传输，如录制回放
+
+	RetryMaxAttempts int           // 非打印接口的最大尝试次数
+	RetryBackoff     time.Duration // 首次重试等待时间
+	RetryMaxBackoff  time.Duration // 最长重试等待时间
+	RateLimit        float64       // 每秒最多请求数
+	RateBurst        int           // 突发请求数
+
+	Credentials CredentialsProvider // 每次请求获取凭证
 }
 
-// Client is the feie client.
+// Client is the feie client, it is safe for concurrent use.
 type Client struct {
 	request    *protocol.Request
 	response   *protocol.Response
-	logger     Logger
+	logger     atomic.Value // loggerHolder, replaced by SetLogger while others log
 	op         options
 	secretInfo rsa.SecretInfo
-	sysTime    string
+	mu         sync.RWMutex
 	user       string
 	ukey       string
+	hc         *client.Client
+	hcErr      error
+	hcOnce     sync.Once
+	idemLocks  idempotencyLocks
+	limiter    *rateLimiter
+	scoped     bool
 }
 
 // Logger is the logger interface.
@@ -58,6 +84,16 @@ type Logger hlog.FullLogger
 // Level is the logger level.
 type Level hlog.Level
 
+// LogFileConfig is the log file and its rotation.
+type LogFileConfig struct {
+	Filename   string // 日志文件，必填
+	MaxSize    int    // 单个文件最大 MB，默认 10
+	MaxBackups int    // 保留的旧文件数，默认 10
+	MaxAge     int    // 旧文件保留天数，默认 30
+	Compress   bool   // 是否 gzip 压缩旧文件
+	LocalTime  bool   // 旧文件名是否使用本地时间，默认 UTC
+}
+
 // Option The option is a payment option.
 type Option func(o *options)
 
@@ -117,20 +153,98 @@ func WithHashType(hashType gocrypto.Hash) Option {
 	}
 }
 
-// WithLogPath sets the log path.
+// WithLogPath writes the log to logPath/feie.log with the default rotation, see WithFileLog.
 func WithLogPath(logPath string) Option {
 	return func(o *options) {
 		o.LogPath = logPath
 	}
 }
 
-// WithLevel sets the level.
+// WithLogger sets the logger, the client does not change its level or output.
+// Without a logger or a log file the client logs nothing.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.Logger = logger
+	}
+}
+
+// WithFileLog writes the log as JSON lines to a rotated file, it takes precedence over WithLogger.
+// Zero fields use the defaults of LogFileConfig.
+func WithFileLog(cfg LogFileConfig) Option {
+	return func(o *options) {
+		o.LogFile = &cfg
+	}
+}
+
+// WithLevel sets the level of the log file, it has no effect on the logger of WithLogger.
 func WithLevel(level Level) Option {
 	return func(o *options) {
 		o.Level = level
 	}
 }
 
+// WithBatchConcurrency sets the number of chunks AddPrinters and DeletePrinters run concurrently.
+func WithBatchConcurrency(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.BatchConcurrency = n
+		}
+	}
+}
+
+// WithIdempotencyStore sets the store remembering the order ID of every idempotency key,
+// default a MemoryIdempotencyStore, nil disables idempotency keys.
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(o *options) {
+		o.IdempotencyStore = store
+	}
+}
+
+// WithIdempotencyTTL sets how long an idempotency key is remembered, default 24 hours.
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl > 0 {
+			o.IdempotencyTTL = ttl
+		}
+	}
+}
+
+// WithTransport sets the transport the signed form is sent with, default posts it with the
+// hertz client. SetRequest and Response only apply to the default transport.
+func WithTransport(transport Transport) Option {
+	return func(o *options) {
+		o.Transport = transport
+	}
+}
+
+// WithRetry retries the calls failing before a response is received up to maxAttempts times in
+// total, waiting backoff doubled on every retry up to maxBackoff. Open_printMsg and
+// Open_printLabelMsg are never retried: after a timeout the ticket may be printed. Send them with
+// an idempotency key instead, a retry of the key then returns ErrIdempotencyInDoubt, not a second ticket.
+func WithRetry(maxAttempts int, backoff, maxBackoff time.Duration) Option {
+	return func(o *options) {
+		o.RetryMaxAttempts = maxAttempts
+		o.RetryBackoff = backoff
+		o.RetryMaxBackoff = maxBackoff
+	}
+}
+
+// WithRateLimit limits the requests sent by the client to rps per second with bursts of burst.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(o *options) {
+		o.RateLimit = rps
+		o.RateBurst = burst
+	}
+}
+
+// WithCredentialsProvider signs every request with the credentials returned by provider, such as
+// FileCredentials rotating the UKEY. It takes precedence over WithUser, WithUserKey, SetUserKey and req.User.
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(o *options) {
+		o.Credentials = provider
+	}
+}
+
 // PrinterAddReq is the request body for adding a printer.
 type PrinterAddReq struct {
 	User           string `json:"user" description:"飞鹅云后台注册用户名。"`
@@ -139,6 +253,8 @@ type PrinterAddReq struct {
 	APIName        string `json:"apiname" description:"固定值Open_printerAddlist。"`
 	Debug          int    `json:"debug,string,omitempty" description:"debug=1返回非json格式的数据。仅测试时候使用。"`
 	PrinterContent string `json:"printerContent" description:"打印机编号(必填) # 打印机识别码(必填) # 备注名称(选填) # 流量卡号码(选填)，多台打印机请换行（\n）添加新打印机信息，每次最多100台。"`
+	// Printers builds PrinterContent when PrinterContent is empty, see BuildPrinterContent.
+	Printers []PrinterSpec `json:"-" description:"结构化的打印机列表，PrinterContent为空时使用。"`
 }
 
 // PrinterAddResp is the response body for adding a printer.
@@ -184,6 +300,10 @@ type PrintMsgReq struct {
 	SN      string `json:"sn" description:"打印机编号。"`
 	Content string `json:"content" description:"打印内容。"`
 	Times   int    `json:"times,omitempty" description:"打印联数，最大支持10联。"`
+	// IdempotencyKey deduplicates retries: a repeated request with the same key and SN returns the
+	// order ID recorded for the first one instead of printing again, see WithIdempotencyStore.
+	// When the first one failed after it may have reached feieyun, it returns ErrIdempotencyInDoubt.
+	IdempotencyKey string `json:"idempotencyKey,omitempty" description:"幂等键，不发送给飞鹅云。"`
 }
 
 // PrintMsgResp is the response body for printing a message.
@@ -206,6 +326,10 @@ type PrintLabelMsgReq struct {
 	Content string `json:"content" description:"打印内容。"`
 	Times   int    `json:"times,omitempty" description:"打印联数，最大支持10联。"`
 	Img     string `json:"img" description:"图片base64编码。"`
+	// IdempotencyKey deduplicates retries: a repeated request with the same key and SN returns the
+	// order ID recorded for the first one instead of printing again, see WithIdempotencyStore.
+	// When the first one failed after it may have reached feieyun, it returns ErrIdempotencyInDoubt.
+	IdempotencyKey string `json:"idempotencyKey,omitempty" description:"幂等键，不发送给飞鹅云。"`
 }
 
 // PrintLabelMsgResp is the response body for printing a label message.
@@ -330,3 +454,8 @@ type AsyncPrinterResultResp struct {
 	Status     int    `json:"status" description:"订单状态"`
 	Stime      int    `json:"stime" description:"订单状态变更UNIX时间戳，10位，精确到秒。"`
 }
+
+// Status returns the parsed printer status.
+func (r *QueryPrinterStatusResp) Status() PrinterStatus {
+	return ParsePrinterStatus(r.Data)
+}
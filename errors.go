@@ -0,0 +1,101 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// APIError is returned when feieyun answers with a non-zero ret.
+type APIError struct {
+	API string `json:"api" description:"接口名称。"`
+	Ret int    `json:"ret" description:"错误码。"`
+	Msg string `json:"msg" description:"错误信息。"`
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	return "feie: " + e.API + " ret " + strconv.Itoa(e.Ret) + ": " + e.Msg
+}
+
+// BatchError collects the errors of the parts that failed in a batch call,
+// the parts that succeeded are still applied.
+type BatchError struct {
+	Errors []error
+}
+
+// Error implements the error interface.
+func (e *BatchError) Error() string {
+	var b strings.Builder
+	b.WriteString("feie: ")
+	b.WriteString(strconv.Itoa(len(e.Errors)))
+	b.WriteString(" error(s) in batch")
+	for _, err := range e.Errors {
+		b.WriteString("; ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the collected errors, errors.Is and errors.As walk them from Go 1.20.
+func (e *BatchError) Unwrap() []error {
+	return e.Errors
+}
+
+// Is reports whether any collected error matches target, so errors.Is works before Go 1.20.
+func (e *BatchError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As sets target to the first collected error matching it, so errors.As works before Go 1.20.
+func (e *BatchError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// BroadcastError is the error of one target of Broadcast.
+type BroadcastError struct {
+	SN       string
+	Err      error
+	Rollback bool
+}
+
+// Error implements the error interface.
+func (e *BroadcastError) Error() string {
+	if e.Rollback {
+		return "printer " + e.SN + " clear queue: " + e.Err.Error()
+	}
+	return "printer " + e.SN + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *BroadcastError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,230 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+// ErrNoHealthyPrinter is returned when no printer of a group is online and normal.
+var ErrNoHealthyPrinter = errors.New("feie: no healthy printer in group")
+
+// PrinterGroup is a primary printer and its backups, tried in order.
+type PrinterGroup struct {
+	Name    string   `json:"name" yaml:"name"`
+	Primary string   `json:"primary" yaml:"primary"`
+	Backups []string `json:"backups,omitempty" yaml:"backups,omitempty"`
+}
+
+// Members returns the primary followed by the backups.
+func (g PrinterGroup) Members() []string {
+	return append([]string{g.Primary}, g.Backups...)
+}
+
+// FailoverSkip is a printer of the group that was not used.
+type FailoverSkip struct {
+	SN     string        `json:"sn"`
+	Status PrinterStatus `json:"status"`
+	Reason string        `json:"reason"`
+}
+
+// FailoverResult is the outcome of a print through a PrinterGroup.
+type FailoverResult struct {
+	// SN is the printer that accepted the order.
+	SN      string         `json:"sn"`
+	OrderID string         `json:"orderId"`
+	Skipped []FailoverSkip `json:"skipped,omitempty"`
+	// Cleared lists the printers whose queue was cleared with Open_delPrinterSqs.
+	Cleared []string `json:"cleared,omitempty"`
+}
+
+// FailoverOption is the option of Failover.
+type FailoverOption func(f *Failover)
+
+// WithFailoverStatusTTL sets how long a printer status is cached, default 30 seconds.
+func WithFailoverStatusTTL(d time.Duration) FailoverOption {
+	return func(f *Failover) {
+		if d > 0 {
+			f.ttl = d
+		}
+	}
+}
+
+// WithFailoverClearQueue clears the queue of an unhealthy printer with Open_delPrinterSqs
+// when it is skipped, so the tickets it holds do not print late once it recovers.
+// The queue is cleared once per outage, it is all the tickets of the printer, not only this group's.
+func WithFailoverClearQueue(clear bool) FailoverOption {
+	return func(f *Failover) {
+		f.clearQueue = clear
+	}
+}
+
+// WithFailoverClock sets the clock, default the system clock.
+func WithFailoverClock(clock Clock) FailoverOption {
+	return func(f *Failover) {
+		f.clock = clock
+	}
+}
+
+// statusEntry is a cached printer status.
+type statusEntry struct {
+	status PrinterStatus
+	at     time.Time
+}
+
+// Failover prints through printer groups, skipping the printers whose cached
+// Open_queryPrinterStatus result is not online and normal.
+type Failover struct {
+	client     *Client
+	ttl        time.Duration
+	clearQueue bool
+	clock      Clock
+
+	mu      sync.Mutex
+	cache   map[string]statusEntry
+	cleared map[string]bool
+}
+
+// NewFailover returns a new Failover.
+func NewFailover(c *Client, opts ...FailoverOption) *Failover {
+	f := &Failover{
+		client:  c,
+		ttl:     30 * time.Second,
+		clock:   systemClock{},
+		cache:   make(map[string]statusEntry),
+		cleared: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Observe records a status known from elsewhere, such as a Monitor callback.
+func (f *Failover) Observe(sn string, status PrinterStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cache[sn] = statusEntry{status: status, at: f.clock.Now()}
+	if status.Healthy() {
+		delete(f.cleared, sn)
+	}
+}
+
+// Status returns the cached status of the printer, querying it when the cache expired.
+func (f *Failover) Status(ctx context.Context, sn string) (PrinterStatus, error) {
+	f.mu.Lock()
+	entry, ok := f.cache[sn]
+	f.mu.Unlock()
+	if ok && f.clock.Now().Sub(entry.at) < f.ttl {
+		return entry.status, nil
+	}
+	status, err := f.client.printerStatus(ctx, sn)
+	if err != nil {
+		return PrinterStatusUnknown, err
+	}
+	f.Observe(sn, status)
+	return status, nil
+}
+
+// PrintMsg prints the request on the first healthy printer of the group, req.SN is ignored.
+// The idempotency key is scoped by printer, a retry only finds the order of the same printer.
+func (f *Failover) PrintMsg(ctx context.Context, group PrinterGroup, req *PrintMsgReq) (*FailoverResult, error) {
+	return f.print(ctx, group, func(sn string) *Job {
+		msg := *req
+		msg.SN = sn
+		return &Job{Kind: JobPrintMsg, Msg: &msg}
+	})
+}
+
+// PrintLabelMsg prints the request on the first healthy printer of the group, req.SN is ignored.
+// The idempotency key is scoped by printer, a retry only finds the order of the same printer.
+func (f *Failover) PrintLabelMsg(ctx context.Context, group PrinterGroup, req *PrintLabelMsgReq) (*FailoverResult, error) {
+	return f.print(ctx, group, func(sn string) *Job {
+		label := *req
+		label.SN = sn
+		return &Job{Kind: JobPrintLabelMsg, Label: &label}
+	})
+}
+
+// print tries the members of the group in order. A printer answering the request with an
+// API error is skipped too, but any other failure is returned: the request may have reached
+// feieyun and printing on a backup could print the ticket twice.
+func (f *Failover) print(ctx context.Context, group PrinterGroup, job func(sn string) *Job) (*FailoverResult, error) {
+	result := &FailoverResult{}
+	for _, sn := range group.Members() {
+		if sn == "" {
+			continue
+		}
+		status, err := f.Status(ctx, sn)
+		if err != nil {
+			result.Skipped = append(result.Skipped, FailoverSkip{SN: sn, Status: status, Reason: err.Error()})
+			continue
+		}
+		if !status.Healthy() {
+			result.Skipped = append(result.Skipped, FailoverSkip{SN: sn, Status: status, Reason: "printer " + status.String()})
+			if f.clearQueue && f.clear(ctx, sn) {
+				result.Cleared = append(result.Cleared, sn)
+			}
+			continue
+		}
+		orderID, err := f.client.sendJob(ctx, job(sn))
+		var apiErr *APIError
+		if err != nil && (errors.As(err, &apiErr) || requestNotSent(err)) {
+			result.Skipped = append(result.Skipped, FailoverSkip{SN: sn, Status: status, Reason: err.Error()})
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("feie: failover print on %s: %w", sn, err)
+		}
+		result.SN, result.OrderID = sn, orderID
+		return result, nil
+	}
+	return result, ErrNoHealthyPrinter
+}
+
+// clear clears the queue of the unhealthy printer once per outage and reports whether it did.
+func (f *Failover) clear(ctx context.Context, sn string) bool {
+	f.mu.Lock()
+	if f.cleared[sn] {
+		f.mu.Unlock()
+		return false
+	}
+	f.cleared[sn] = true
+	f.mu.Unlock()
+
+	resp, err := f.client.OpenDelPrinterSQS(ctx, &DelPrinterSQSReq{SN: sn})
+	if err == nil && resp.Ret != 0 {
+		err = &APIError{API: delPrinterSqs, Ret: resp.Ret, Msg: resp.Msg}
+	}
+	if err != nil {
+		f.client.logEvent(ctx, hlog.LevelWarn, "feie failover clear printer queue failed",
+			Field{Key: "sn", Value: sn}, Field{Key: "error", Value: err})
+		f.mu.Lock()
+		delete(f.cleared, sn)
+		f.mu.Unlock()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFailover_PrintMsg(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		calls  = map[string]int{}
+		status = map[string]string{"sn1": "在线，工作状态不正常。", "sn2": "离线。", "sn3": "在线，工作状态正常。"}
+	)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[form[APINameField]+" "+form[SNField]]++
+		switch form[APINameField] {
+		case queryPrinterStatus:
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": status[form[SNField]]}
+		case delPrinterSqs:
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": true}
+		default:
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_20160919184316_1419533539"}
+		}
+	})
+	var (
+		ctx   = context.Background()
+		clock = newFakeClock()
+		c     = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		f     = NewFailover(c, WithFailoverClock(clock), WithFailoverClearQueue(true))
+		group = PrinterGroup{Name: "kitchen", Primary: "sn1", Backups: []string{"sn2", "sn3"}}
+	)
+	for i := 0; i < 2; i++ {
+		result, err := f.PrintMsg(ctx, group, &PrintMsgReq{Content: "ticket"})
+		if err != nil {
+			t.Fatalf("PrintMsg() error = %v", err)
+		}
+		if result.SN != "sn3" || result.OrderID != "sn3_20160919184316_1419533539" || len(result.Skipped) != 2 {
+			t.Errorf("PrintMsg() result = %+v", result)
+		}
+		wantCleared := []string{"sn1", "sn2"}
+		if i > 0 {
+			wantCleared = nil
+		}
+		if !reflect.DeepEqual(result.Cleared, wantCleared) {
+			t.Errorf("PrintMsg() cleared = %v, want %v", result.Cleared, wantCleared)
+		}
+	}
+	if n := calls[queryPrinterStatus+" sn1"]; n != 1 {
+		t.Errorf("status queries = %d, want 1 within the TTL", n)
+	}
+
+	f.Observe("sn1", PrinterStatusOnline)
+	result, err := f.PrintMsg(ctx, group, &PrintMsgReq{Content: "ticket"})
+	if err != nil || result.SN != "sn1" {
+		t.Errorf("PrintMsg() after recovery = %+v, %v", result, err)
+	}
+
+	mu.Lock()
+	status["sn3"] = "离线。"
+	mu.Unlock()
+	f.Observe("sn1", PrinterStatusOffline)
+	clock.Advance(time.Minute)
+	if _, err = f.PrintMsg(ctx, group, &PrintMsgReq{Content: "ticket"}); err != ErrNoHealthyPrinter {
+		t.Errorf("PrintMsg() error = %v, want %v", err, ErrNoHealthyPrinter)
+	}
+}
+
+func TestFailover_SendErrors(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		printed []string
+		fail    = map[string]string{}
+	)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if form[APINameField] == queryPrinterStatus {
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": "在线，工作状态正常。"}
+		}
+		switch fail[form[SNField]] {
+		case "api":
+			return map[string]interface{}{"ret": 1002, "msg": "打印机编号错误"}
+		case "timeout":
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": 1}
+		}
+		printed = append(printed, form[SNField])
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_20160919184316_1419533539"}
+	})
+	var (
+		ctx   = context.Background()
+		store = NewMemoryIdempotencyStore()
+		c     = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL), WithIdempotencyStore(store))
+		f     = NewFailover(c)
+		group = PrinterGroup{Name: "kitchen", Primary: "sn1", Backups: []string{"sn2"}}
+	)
+	tests := []struct {
+		name    string
+		fail    string
+		wantSN  string
+		wantErr bool
+	}{
+		{"api error fails over", "api", "sn2", false},
+		{"ambiguous error is returned", "timeout", "", true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			fail["sn1"], printed = tt.fail, nil
+			mu.Unlock()
+			key := "order-" + strconv.Itoa(i)
+			result, err := f.PrintMsg(ctx, group, &PrintMsgReq{Content: "ticket", IdempotencyKey: key})
+			if (err != nil) != tt.wantErr || result.SN != tt.wantSN {
+				t.Fatalf("PrintMsg() = %+v, %v, want sn %q, error %v", result, err, tt.wantSN, tt.wantErr)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if want := tt.wantSN; (want == "" && len(printed) != 0) || (want != "" && !reflect.DeepEqual(printed, []string{want})) {
+				t.Errorf("printed on %v, want %q", printed, want)
+			}
+			if tt.wantSN != "" {
+				if _, ok, _ := store.Get(ctx, "user|"+printMsg+"|"+tt.wantSN+"|"+key); !ok {
+					t.Errorf("idempotency key not scoped by %s", tt.wantSN)
+				}
+			}
+		})
+	}
+}
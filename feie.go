@@ -21,10 +21,9 @@ package feie
 import (
 	"context"
 	"crypto/sha1"
-	"crypto/tls"
 	"encoding/hex"
 	"errors"
-	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -33,7 +32,6 @@ import (
 	"github.com/cloudwego/hertz/pkg/app/client"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/cloudwego/hertz/pkg/protocol"
-	"github.com/cloudwego/hertz/pkg/protocol/consts"
 	"github.com/houseme/gocrypto"
 	"github.com/houseme/gocrypto/rsa"
 
@@ -42,18 +40,7 @@ import (
 
 // New returns a new feie client.
 func New(ctx context.Context, opts ...Option) *Client {
-	op := options{
-		TimeOut:   30 * time.Second,
-		Gateway:   gateway,
-		UserAgent: userAgent,
-		DataType:  gocrypto.Base64,
-		HashType:  gocrypto.SHA256,
-		LogPath:   os.TempDir(),
-		Level:     Level(hlog.LevelDebug),
-	}
-	for _, option := range opts {
-		option(&op)
-	}
+	op := newOptions(opts...)
 	c := &Client{
 		op: op,
 		secretInfo: rsa.SecretInfo{
@@ -64,39 +51,109 @@ func New(ctx context.Context, opts ...Option) *Client {
 			PrivateKeyType:     gocrypto.PKCS8,
 			HashType:           op.HashType,
 		},
-		logger:   internal.InitLog(ctx, op.LogPath, hlog.Level(op.Level)),
 		request:  &protocol.Request{},
 		response: &protocol.Response{},
 		user:     op.User,
 		ukey:     op.UKey,
+		limiter:  newRateLimiter(op.RateLimit, op.RateBurst),
 	}
-	c.logger.SetLevel(hlog.Level(op.Level))
-	c.logger.CtxDebugf(ctx, "feie client init success %s", c.op.Level)
+	c.logger.Store(loggerHolder{newLogger(op)})
+	c.logEvent(ctx, hlog.LevelDebug, "feie client init success", Field{Key: "gateway", Value: op.Gateway})
 	return c
 }
 
-// SetRequest sets the request.
+// newOptions returns the defaults with opts applied.
+func newOptions(opts ...Option) options {
+	op := options{
+		TimeOut:   30 * time.Second,
+		Gateway:   gateway,
+		UserAgent: userAgent,
+		DataType:  gocrypto.Base64,
+		HashType:  gocrypto.SHA256,
+		Level:     Level(hlog.LevelInfo),
+
+		BatchConcurrency: 4,
+		IdempotencyStore: NewMemoryIdempotencyStore(),
+		IdempotencyTTL:   24 * time.Hour,
+	}
+	for _, option := range opts {
+		option(&op)
+	}
+	return op
+}
+
+// newLogger returns the log file of op, then the logger of op, then a logger discarding every log.
+// It never touches the global hlog logger.
+func newLogger(op options) Logger {
+	cfg := op.LogFile
+	if cfg == nil && op.LogPath != "" {
+		cfg = &LogFileConfig{Filename: filepath.Join(op.LogPath, "feie.log")}
+	}
+	if cfg != nil && cfg.Filename != "" {
+		fc := internal.FileConfig{
+			Filename:   cfg.Filename,
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+			Compress:   cfg.Compress,
+			LocalTime:  cfg.LocalTime,
+		}
+		if fc.MaxSize <= 0 {
+			fc.MaxSize = 10
+		}
+		if fc.MaxBackups <= 0 {
+			fc.MaxBackups = 10
+		}
+		if fc.MaxAge <= 0 {
+			fc.MaxAge = 30
+		}
+		return internal.NewFileLogger(fc, hlog.Level(op.Level))
+	}
+	if op.Logger != nil {
+		return op.Logger
+	}
+	return internal.NopLogger{}
+}
+
+// SetRequest sets the request template, its headers are copied into every request.
 func (c *Client) SetRequest(request *protocol.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.request = request
 }
 
-// Response return request response content
+// Response return the content of the latest request response
 func (c *Client) Response() *protocol.Response {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.response
 }
 
-// SetLogger set feie logger
+// SetLogger set feie logger, a nil logger discards every log. It may be called while the client is in use.
 func (c *Client) SetLogger(logger hlog.FullLogger) {
-	c.logger = logger
+	if logger == nil {
+		logger = internal.NopLogger{}
+	}
+	c.logger.Store(loggerHolder{logger})
 }
 
-// SetUserKey sets the user key.
+// SetUserKey sets the user key, it has no effect on the clients of a Registry.
 func (c *Client) SetUserKey(ukey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scoped {
+		return
+	}
 	c.ukey = ukey
 }
 
 // Reset reset the feie client.
 func (c *Client) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scoped {
+		return
+	}
 	if strings.TrimSpace(c.op.User) != "" {
 		c.user = c.op.User
 	}
@@ -105,45 +162,171 @@ func (c *Client) Reset() {
 	}
 }
 
-// sha1Sign returns the sha1 sign.
-func (c *Client) sha1Sign() string {
-	s := sha1.Sum([]byte(c.user + c.ukey + c.sysTime))
+// credentials returns the user and ukey used to sign the next request, a non-empty reqUser
+// replaces the user for this request only, except on the clients of a Registry.
+func (c *Client) credentials(reqUser string) (user, ukey string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if reqUser != "" && !c.scoped {
+		return reqUser, c.ukey
+	}
+	return c.user, c.ukey
+}
+
+// sha1Sign returns the sha1 sign of user+ukey+stime.
+func sha1Sign(user, ukey, sysTime string) string {
+	s := sha1.Sum([]byte(user + ukey + sysTime))
 	return hex.EncodeToString(s[:])
 }
 
-// generateTime Generate current time
-func (c *Client) generateTime() {
-	c.sysTime = strconv.FormatInt(time.Now().Unix(), 10)
+// httpClient returns the hertz client shared by all requests.
+func (c *Client) httpClient() (*client.Client, error) {
+	c.hcOnce.Do(func() {
+		c.hc, c.hcErr = newHTTPClient(c.op.TimeOut)
+	})
+	return c.hc, c.hcErr
 }
 
-// doRequest does the request.
-func (c *Client) doRequest(ctx context.Context, formData map[string]string) error {
-	c.generateTime()
-
-	formData[UserField] = c.user
-	formData[SysTimeField] = c.sysTime
-	formData[SigField] = c.sha1Sign()
-	c.logger.Debug(ctx, "formData:", formData)
-	c.request.SetMultipartFormData(formData)
-	c.request.SetRequestURI(c.op.Gateway)
-	c.request.Header.SetMethod(consts.MethodPost)
-	c.request.Header.SetUserAgentBytes(c.op.UserAgent)
-	c.logger.Debug(ctx, "request content: ", c.request)
-
-	hc, err := client.NewClient(client.WithTLSConfig(&tls.Config{
-		InsecureSkipVerify: true,
-	}), client.WithDialTimeout(c.op.TimeOut))
+// resolveCredentials returns the user and ukey signing a request for reqUser: those of the
+// credentials provider when set, otherwise those of credentials.
+func (c *Client) resolveCredentials(ctx context.Context, reqUser string) (user, ukey string, err error) {
+	if c.op.Credentials == nil {
+		user, ukey = c.credentials(reqUser)
+		return user, ukey, nil
+	}
+	creds, err := c.op.Credentials.Credentials(ctx)
 	if err != nil {
-		return err
+		return "", "", &notSentError{err: err}
 	}
+	return creds.User, creds.UKey, nil
+}
 
-	c.logger.Debug(ctx, "do request start")
-	err = hc.Do(ctx, c.request, c.response)
+// doRequest does the request signed for reqUser and decodes the response body into resp.
+// Every call uses its own request, response and user, so the client is safe for concurrent use.
+func (c *Client) doRequest(ctx context.Context, reqUser string, formData map[string]string, resp interface{}) error {
+	user, ukey, err := c.resolveCredentials(ctx, reqUser)
 	if err != nil {
 		return err
 	}
-	c.logger.Debug(ctx, "do request end")
-	return nil
+	return c.doSigned(ctx, user, ukey, formData, resp)
+}
+
+// doSigned does the request signed with user and ukey and decodes the response body into resp.
+func (c *Client) doSigned(ctx context.Context, user, ukey string, formData map[string]string, resp interface{}) error {
+	var (
+		sysTime = strconv.FormatInt(time.Now().Unix(), 10)
+		body    []byte
+		err     error
+	)
+	formData[UserField] = user
+	formData[SysTimeField] = sysTime
+	formData[SigField] = sha1Sign(user, ukey, sysTime)
+
+	start := time.Now()
+	body, err = c.send(ctx, formData)
+	if err == nil && len(body) == 0 {
+		err = errors.New("response is empty")
+	}
+	if err == nil {
+		err = sonic.Unmarshal(body, resp)
+	}
+	c.logRequest(ctx, formData, body, time.Since(start), err)
+	return err
+}
+
+// respMeta is the common part of the responses, used for logging.
+type respMeta struct {
+	Ret  int         `json:"ret"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data"`
+}
+
+// logRequest logs a request with the fields api, sn, order_id, ret and latency, at debug level
+// when it succeeds and at warn level when it fails or ret != 0.
+func (c *Client) logRequest(ctx context.Context, formData map[string]string, body []byte, latency time.Duration, err error) {
+	if c.logDisabled() {
+		return
+	}
+	api := formData[APINameField]
+	fields := []Field{{Key: "api", Value: api}}
+	if sn := formData[SNField]; sn != "" {
+		fields = append(fields, Field{Key: "sn", Value: sn})
+	}
+	orderID := formData[OrderIDField]
+	if err != nil {
+		if orderID != "" {
+			fields = append(fields, Field{Key: "order_id", Value: orderID})
+		}
+		fields = append(fields, Field{Key: "latency", Value: latency}, Field{Key: "error", Value: err})
+		c.logEvent(ctx, hlog.LevelWarn, "feie request failed", fields...)
+		return
+	}
+	var meta respMeta
+	_ = sonic.Unmarshal(body, &meta)
+	if id, ok := meta.Data.(string); ok && orderID == "" && meta.Ret == 0 && (api == printMsg || api == printLabelMsg) {
+		orderID = id
+	}
+	if orderID != "" {
+		fields = append(fields, Field{Key: "order_id", Value: orderID})
+	}
+	fields = append(fields, Field{Key: "ret", Value: meta.Ret}, Field{Key: "latency", Value: latency})
+	if meta.Ret != 0 {
+		fields = append(fields, Field{Key: "msg", Value: meta.Msg})
+		c.logEvent(ctx, hlog.LevelWarn, "feie request returned an error", fields...)
+		return
+	}
+	c.logEvent(ctx, hlog.LevelDebug, "feie request", fields...)
+}
+
+// send sends the form with the rate limit and retries set by WithRateLimit and WithRetry.
+func (c *Client) send(ctx context.Context, formData map[string]string) (body []byte, err error) {
+	api := formData[APINameField]
+	for attempt := 1; ; attempt++ {
+		if err = c.limiter.wait(ctx); err != nil {
+			return nil, &notSentError{err: err}
+		}
+		if c.op.Transport != nil {
+			body, err = c.op.Transport.Do(ctx, c.op.Gateway, formData)
+		} else {
+			body, err = c.post(ctx, formData)
+		}
+		if err == nil || attempt >= c.op.RetryMaxAttempts || !retryable(api) || ctx.Err() != nil {
+			return body, err
+		}
+		c.logEvent(ctx, hlog.LevelWarn, "feie request retrying",
+			Field{Key: "api", Value: api}, Field{Key: "attempt", Value: attempt}, Field{Key: "error", Value: err})
+		timer := time.NewTimer(retryBackoff(c.op.RetryBackoff, c.op.RetryMaxBackoff, attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// post posts the form with the hertz client, using the headers set by SetRequest.
+func (c *Client) post(ctx context.Context, formData map[string]string) ([]byte, error) {
+	request := &protocol.Request{}
+	// CopyToSkipBody initializes the trailer of the template, so it needs the write lock.
+	c.mu.Lock()
+	if c.request != nil {
+		c.request.CopyToSkipBody(request)
+	}
+	c.mu.Unlock()
+
+	hc, err := c.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	response, err := postForm(ctx, hc, request, c.op.Gateway, c.op.UserAgent, formData)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.response = response
+	c.mu.Unlock()
+	return response.Body(), nil
 }
 
 // OpenPrintMsg 打印订单
@@ -157,9 +340,6 @@ func (c *Client) OpenPrintMsg(ctx context.Context, req *PrintMsgReq) (resp *Prin
 	formData[APINameField] = printMsg
 	formData[SNField] = req.SN
 	formData[ContentField] = req.Content
-	if req.User != "" {
-		c.user = req.User
-	}
 	if req.Expired > time.Now().Unix() {
 		formData[ExpiredField] = strconv.FormatInt(req.Expired, 10)
 	}
@@ -170,18 +350,29 @@ func (c *Client) OpenPrintMsg(ctx context.Context, req *PrintMsgReq) (resp *Prin
 		formData[BackURLField] = req.BackURL
 	}
 
-	if err = c.doRequest(ctx, formData); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "do request response body:", string(c.response.Body()))
-	if !c.response.HasBodyBytes() {
-		err = errors.New("response is empty")
-		return
-	}
-	if err = sonic.Unmarshal(c.response.Body(), &resp); err != nil {
-		return
+	user, ukey, err := c.resolveCredentials(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	key := c.idempotencyKey(printMsg, user, req.SN, req.IdempotencyKey)
+	if key != "" {
+		defer c.idemLocks.lock(key)()
+		var orderID string
+		if orderID, err = c.beginIdempotent(ctx, key); err != nil || orderID != "" {
+			if orderID != "" {
+				resp = &PrintMsgResp{Msg: "ok", Data: orderID}
+			}
+			return
+		}
+	}
+	err = c.doSigned(ctx, user, ukey, formData, &resp)
+	if key != "" {
+		var orderID string
+		if err == nil && resp.Ret == 0 {
+			orderID = resp.Data
+		}
+		c.endIdempotent(ctx, key, orderID, err)
 	}
-	c.logger.Debug(ctx, "json Unmarshal resp result:", resp)
 	return
 }
 
@@ -193,6 +384,7 @@ func (c *Client) OpenPrintMsg(ctx context.Context, req *PrintMsgReq) (resp *Prin
 // 每次最多添加100台。
 // 提示：打印机编号(必填) # 打印机识别码(必填) # 备注名称(选填) # 流量卡号码(选填)，多台打印机请换行（\n）添加新打印机信息，每次最多100行(台)。
 // snlist := "sn1#key1#remark1#carnum1\nsn2#key2#remark2#carnum2"
+// 也可以通过 req.Printers 传入结构化的打印机列表，由 BuildPrinterContent 生成，结果可通过 resp.Data.AddResults() 解析。
 //
 // ----------接口返回值说明----------
 // 正确例子：{"msg":"ok","ret":0,"data":{"ok":["sn#key#remark#carnum","316500011#abcdefgh#快餐前台"],"no":["316500012#abcdefgh#快餐前台#13688889999  （错误：识别码不正确）"]},"serverExecutedTime":3}
@@ -201,21 +393,12 @@ func (c *Client) OpenPrinterAddList(ctx context.Context, req *PrinterAddReq) (re
 	var formData = make(map[string]string, 5)
 	formData[APINameField] = printerAddList
 	formData[PrinterContentField] = req.PrinterContent
-	if req.User != "" {
-		c.user = req.User
+	if strings.TrimSpace(req.PrinterContent) == "" && len(req.Printers) > 0 {
+		if formData[PrinterContentField], err = BuildPrinterContent(req.Printers); err != nil {
+			return
+		}
 	}
-	if err = c.doRequest(ctx, formData); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "do request response body:", string(c.response.Body()))
-	if !c.response.HasBodyBytes() {
-		err = errors.New("response is empty")
-		return
-	}
-	if err = sonic.Unmarshal(c.response.Body(), &resp); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "json Unmarshal resp result:", resp)
+	err = c.doRequest(ctx, req.User, formData, &resp)
 	return
 }
 
@@ -226,21 +409,7 @@ func (c *Client) OpenPrinterDelList(ctx context.Context, req *PrinterDelReq) (re
 	var formData = make(map[string]string, 5)
 	formData[SNListField] = req.SNList
 	formData[APINameField] = printerDelList
-	if req.User != "" {
-		c.user = req.User
-	}
-	if err = c.doRequest(ctx, formData); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "do request response body:", string(c.response.Body()))
-	if !c.response.HasBodyBytes() {
-		err = errors.New("response is empty")
-		return
-	}
-	if err = sonic.Unmarshal(c.response.Body(), &resp); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "json Unmarshal resp result:", resp)
+	err = c.doRequest(ctx, req.User, formData, &resp)
 	return
 }
 
@@ -252,9 +421,6 @@ func (c *Client) OpenPrintLabelMsg(ctx context.Context, req *PrintLabelMsgReq) (
 	formData[APINameField] = printLabelMsg
 	formData[SNField] = req.SN
 	formData[ContentField] = req.Content
-	if req.User != "" {
-		c.user = req.User
-	}
 	if req.Expired > time.Now().Unix() {
 		formData[ExpiredField] = strconv.FormatInt(req.Expired, 10)
 	}
@@ -269,18 +435,29 @@ func (c *Client) OpenPrintLabelMsg(ctx context.Context, req *PrintLabelMsgReq) (
 		formData[ImgField] = req.Img
 	}
 
-	if err = c.doRequest(ctx, formData); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "do request response body:", string(c.response.Body()))
-	if !c.response.HasBodyBytes() {
-		err = errors.New("response is empty")
-		return
-	}
-	if err = sonic.Unmarshal(c.response.Body(), &resp); err != nil {
-		return
+	user, ukey, err := c.resolveCredentials(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	key := c.idempotencyKey(printLabelMsg, user, req.SN, req.IdempotencyKey)
+	if key != "" {
+		defer c.idemLocks.lock(key)()
+		var orderID string
+		if orderID, err = c.beginIdempotent(ctx, key); err != nil || orderID != "" {
+			if orderID != "" {
+				resp = &PrintLabelMsgResp{Msg: "ok", Data: orderID}
+			}
+			return
+		}
+	}
+	err = c.doSigned(ctx, user, ukey, formData, &resp)
+	if key != "" {
+		var orderID string
+		if err == nil && resp.Ret == 0 {
+			orderID = resp.Data
+		}
+		c.endIdempotent(ctx, key, orderID, err)
 	}
-	c.logger.Debug(ctx, "json Unmarshal resp result:", resp)
 	return
 }
 
@@ -292,25 +469,11 @@ func (c *Client) OpenPrinterEdit(ctx context.Context, req *PrinterEditReq) (resp
 	formData[SNField] = req.SN
 	formData[APINameField] = printerEdit
 	formData[NameField] = req.Name
-	if req.User != "" {
-		c.user = req.User
-	}
 	if len(strings.TrimSpace(req.PhoneNum)) > 0 {
 		formData[PhoneNumField] = strings.TrimSpace(req.PhoneNum)
 	}
 
-	if err = c.doRequest(ctx, formData); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "do request response body:", string(c.response.Body()))
-	if !c.response.HasBodyBytes() {
-		err = errors.New("response is empty")
-		return
-	}
-	if err = sonic.Unmarshal(c.response.Body(), &resp); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "json Unmarshal resp result:", resp)
+	err = c.doRequest(ctx, req.User, formData, &resp)
 	return
 }
 
@@ -320,21 +483,7 @@ func (c *Client) OpenDelPrinterSQS(ctx context.Context, req *DelPrinterSQSReq) (
 	var formData = make(map[string]string, 5)
 	formData[SNField] = req.SN
 	formData[APINameField] = delPrinterSqs
-	if req.User != "" {
-		c.user = req.User
-	}
-	if err = c.doRequest(ctx, formData); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "do request response body:", string(c.response.Body()))
-	if !c.response.HasBodyBytes() {
-		err = errors.New("response is empty")
-		return
-	}
-	if err = sonic.Unmarshal(c.response.Body(), &resp); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "json Unmarshal resp result:", resp)
+	err = c.doRequest(ctx, req.User, formData, &resp)
 	return
 }
 
@@ -345,21 +494,7 @@ func (c *Client) OpenQueryOrderState(ctx context.Context, req *QueryOrderStateRe
 	var formData = make(map[string]string, 5)
 	formData[OrderIDField] = req.OrderID
 	formData[APINameField] = queryOrderState
-	if req.User != "" {
-		c.user = req.User
-	}
-	if err = c.doRequest(ctx, formData); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "do request response body:", string(c.response.Body()))
-	if !c.response.HasBodyBytes() {
-		err = errors.New("response is empty")
-		return
-	}
-	if err = sonic.Unmarshal(c.response.Body(), &resp); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "json Unmarshal resp result:", resp)
+	err = c.doRequest(ctx, req.User, formData, &resp)
 	return
 }
 
@@ -371,21 +506,7 @@ func (c *Client) OpenQueryOrderInfoByDate(ctx context.Context, req *QueryOrderIn
 	formData[SNField] = req.SN
 	formData[DateField] = req.Date
 	formData[APINameField] = queryOrderInfoByDate
-	if req.User != "" {
-		c.user = req.User
-	}
-	if err = c.doRequest(ctx, formData); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "do request response body:", string(c.response.Body()))
-	if !c.response.HasBodyBytes() {
-		err = errors.New("response is empty")
-		return
-	}
-	if err = sonic.Unmarshal(c.response.Body(), &resp); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "json Unmarshal resp result:", resp)
+	err = c.doRequest(ctx, req.User, formData, &resp)
 	return
 }
 
@@ -397,21 +518,7 @@ func (c *Client) OpenQueryPrinterStatus(ctx context.Context, req *QueryPrinterSt
 	var formData = make(map[string]string, 5)
 	formData[SNField] = req.SN
 	formData[APINameField] = queryPrinterStatus
-	if req.User != "" {
-		c.user = req.User
-	}
-	if err = c.doRequest(ctx, formData); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "do request response body:", string(c.response.Body()))
-	if !c.response.HasBodyBytes() {
-		err = errors.New("response is empty")
-		return
-	}
-	if err = sonic.Unmarshal(c.response.Body(), &resp); err != nil {
-		return
-	}
-	c.logger.Debug(ctx, "json Unmarshal resp result:", resp)
+	err = c.doRequest(ctx, req.User, formData, &resp)
 	return
 }
 
@@ -448,7 +555,8 @@ func (c *Client) AsyncPrinterResult(ctx context.Context, req *AsyncPrinterResult
 	)
 
 	if verifySign, err = handle.VerifySign(content, req.Sign, c.op.DataType); err != nil {
-		c.logger.Debug(ctx, "AsyncPrinterResult rsa VerifySign failed:", err)
+		c.logEvent(ctx, hlog.LevelWarn, "feie callback verify sign failed",
+			Field{Key: "order_id", Value: req.OrderID}, Field{Key: "error", Value: err})
 		return
 	}
 	resp = &AsyncPrinterResultResp{
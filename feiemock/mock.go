@@ -0,0 +1,196 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+// Package feiemock provides a mock of feie.API that records calls and returns scripted responses.
+//
+//	m := &feiemock.API{
+//		OpenPrintMsgFunc: func(ctx context.Context, req *feie.PrintMsgReq) (*feie.PrintMsgResp, error) {
+//			return &feie.PrintMsgResp{Msg: "ok", Data: req.SN + "_20160919184316_1419533539"}, nil
+//		},
+//	}
+//	service := NewService(m)
+//	calls := m.Calls("OpenPrintMsg")
+package feiemock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/houseme/feie"
+)
+
+// ErrNotScripted is returned by a method whose func is not set.
+var ErrNotScripted = errors.New("feiemock: method not scripted")
+
+// Call is one recorded call.
+type Call struct {
+	Method string
+	Req    interface{}
+}
+
+// API is a mock of feie.API, it is safe for concurrent use.
+// Every method records the call, then calls its func, or returns ErrNotScripted when it is nil.
+type API struct {
+	OpenPrinterAddListFunc       func(ctx context.Context, req *feie.PrinterAddReq) (*feie.PrinterAddResp, error)
+	OpenPrinterDelListFunc       func(ctx context.Context, req *feie.PrinterDelReq) (*feie.PrinterDelResp, error)
+	OpenPrintMsgFunc             func(ctx context.Context, req *feie.PrintMsgReq) (*feie.PrintMsgResp, error)
+	OpenPrintLabelMsgFunc        func(ctx context.Context, req *feie.PrintLabelMsgReq) (*feie.PrintLabelMsgResp, error)
+	OpenPrinterEditFunc          func(ctx context.Context, req *feie.PrinterEditReq) (*feie.PrinterEditResp, error)
+	OpenDelPrinterSQSFunc        func(ctx context.Context, req *feie.DelPrinterSQSReq) (*feie.DelPrinterSQSResp, error)
+	OpenQueryOrderStateFunc      func(ctx context.Context, req *feie.QueryOrderStateReq) (*feie.QueryOrderStateResp, error)
+	OpenQueryOrderInfoByDateFunc func(ctx context.Context, req *feie.QueryOrderInfoByDateReq) (*feie.QueryOrderInfoByDateResp, error)
+	OpenQueryPrinterStatusFunc   func(ctx context.Context, req *feie.QueryPrinterStatusReq) (*feie.QueryPrinterStatusResp, error)
+	AsyncPrinterResultFunc       func(ctx context.Context, req *feie.AsyncPrinterResultReq) (*feie.AsyncPrinterResultResp, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ feie.API = (*API)(nil)
+
+// record records the call.
+func (m *API) record(method string, req interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Req: req})
+}
+
+// Calls returns the recorded calls of the methods in call order, no method returns every call.
+func (m *API) Calls(methods ...string) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make([]Call, 0, len(m.calls))
+	for _, call := range m.calls {
+		if len(methods) == 0 || contains(methods, call.Method) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls.
+func (m *API) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func notScripted(method string) error {
+	return fmt.Errorf("%w: %s", ErrNotScripted, method)
+}
+
+// OpenPrinterAddList implements feie.API.
+func (m *API) OpenPrinterAddList(ctx context.Context, req *feie.PrinterAddReq) (*feie.PrinterAddResp, error) {
+	m.record("OpenPrinterAddList", req)
+	if m.OpenPrinterAddListFunc == nil {
+		return nil, notScripted("OpenPrinterAddList")
+	}
+	return m.OpenPrinterAddListFunc(ctx, req)
+}
+
+// OpenPrinterDelList implements feie.API.
+func (m *API) OpenPrinterDelList(ctx context.Context, req *feie.PrinterDelReq) (*feie.PrinterDelResp, error) {
+	m.record("OpenPrinterDelList", req)
+	if m.OpenPrinterDelListFunc == nil {
+		return nil, notScripted("OpenPrinterDelList")
+	}
+	return m.OpenPrinterDelListFunc(ctx, req)
+}
+
+// OpenPrintMsg implements feie.API.
+func (m *API) OpenPrintMsg(ctx context.Context, req *feie.PrintMsgReq) (*feie.PrintMsgResp, error) {
+	m.record("OpenPrintMsg", req)
+	if m.OpenPrintMsgFunc == nil {
+		return nil, notScripted("OpenPrintMsg")
+	}
+	return m.OpenPrintMsgFunc(ctx, req)
+}
+
+// OpenPrintLabelMsg implements feie.API.
+func (m *API) OpenPrintLabelMsg(ctx context.Context, req *feie.PrintLabelMsgReq) (*feie.PrintLabelMsgResp, error) {
+	m.record("OpenPrintLabelMsg", req)
+	if m.OpenPrintLabelMsgFunc == nil {
+		return nil, notScripted("OpenPrintLabelMsg")
+	}
+	return m.OpenPrintLabelMsgFunc(ctx, req)
+}
+
+// OpenPrinterEdit implements feie.API.
+func (m *API) OpenPrinterEdit(ctx context.Context, req *feie.PrinterEditReq) (*feie.PrinterEditResp, error) {
+	m.record("OpenPrinterEdit", req)
+	if m.OpenPrinterEditFunc == nil {
+		return nil, notScripted("OpenPrinterEdit")
+	}
+	return m.OpenPrinterEditFunc(ctx, req)
+}
+
+// OpenDelPrinterSQS implements feie.API.
+func (m *API) OpenDelPrinterSQS(ctx context.Context, req *feie.DelPrinterSQSReq) (*feie.DelPrinterSQSResp, error) {
+	m.record("OpenDelPrinterSQS", req)
+	if m.OpenDelPrinterSQSFunc == nil {
+		return nil, notScripted("OpenDelPrinterSQS")
+	}
+	return m.OpenDelPrinterSQSFunc(ctx, req)
+}
+
+// OpenQueryOrderState implements feie.API.
+func (m *API) OpenQueryOrderState(ctx context.Context, req *feie.QueryOrderStateReq) (*feie.QueryOrderStateResp, error) {
+	m.record("OpenQueryOrderState", req)
+	if m.OpenQueryOrderStateFunc == nil {
+		return nil, notScripted("OpenQueryOrderState")
+	}
+	return m.OpenQueryOrderStateFunc(ctx, req)
+}
+
+// OpenQueryOrderInfoByDate implements feie.API.
+func (m *API) OpenQueryOrderInfoByDate(ctx context.Context, req *feie.QueryOrderInfoByDateReq) (*feie.QueryOrderInfoByDateResp, error) {
+	m.record("OpenQueryOrderInfoByDate", req)
+	if m.OpenQueryOrderInfoByDateFunc == nil {
+		return nil, notScripted("OpenQueryOrderInfoByDate")
+	}
+	return m.OpenQueryOrderInfoByDateFunc(ctx, req)
+}
+
+// OpenQueryPrinterStatus implements feie.API.
+func (m *API) OpenQueryPrinterStatus(ctx context.Context, req *feie.QueryPrinterStatusReq) (*feie.QueryPrinterStatusResp, error) {
+	m.record("OpenQueryPrinterStatus", req)
+	if m.OpenQueryPrinterStatusFunc == nil {
+		return nil, notScripted("OpenQueryPrinterStatus")
+	}
+	return m.OpenQueryPrinterStatusFunc(ctx, req)
+}
+
+// AsyncPrinterResult implements feie.API.
+func (m *API) AsyncPrinterResult(ctx context.Context, req *feie.AsyncPrinterResultReq) (*feie.AsyncPrinterResultResp, error) {
+	m.record("AsyncPrinterResult", req)
+	if m.AsyncPrinterResultFunc == nil {
+		return nil, notScripted("AsyncPrinterResult")
+	}
+	return m.AsyncPrinterResultFunc(ctx, req)
+}
@@ -0,0 +1,57 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feiemock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/houseme/feie"
+)
+
+func TestAPI(t *testing.T) {
+	ctx := context.Background()
+	m := &API{
+		OpenPrintMsgFunc: func(ctx context.Context, req *feie.PrintMsgReq) (*feie.PrintMsgResp, error) {
+			return &feie.PrintMsgResp{Msg: "ok", Data: req.SN + "_20160919184316_1"}, nil
+		},
+	}
+	var api feie.API = m
+
+	resp, err := api.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn1", Content: "x"})
+	if err != nil || resp.Data != "sn1_20160919184316_1" {
+		t.Fatalf("OpenPrintMsg() = %+v, %v", resp, err)
+	}
+	if _, err = api.OpenQueryPrinterStatus(ctx, &feie.QueryPrinterStatusReq{SN: "sn1"}); !errors.Is(err, ErrNotScripted) {
+		t.Errorf("OpenQueryPrinterStatus() error = %v, want ErrNotScripted", err)
+	}
+
+	if calls := m.Calls(); len(calls) != 2 {
+		t.Fatalf("Calls() = %+v, want 2 calls", calls)
+	}
+	calls := m.Calls("OpenPrintMsg")
+	if len(calls) != 1 || calls[0].Req.(*feie.PrintMsgReq).SN != "sn1" {
+		t.Errorf("Calls(OpenPrintMsg) = %+v", calls)
+	}
+	m.Reset()
+	if calls = m.Calls(); len(calls) != 0 {
+		t.Errorf("Calls() after Reset = %+v", calls)
+	}
+}
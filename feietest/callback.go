@@ -0,0 +1,198 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feietest
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Callback statuses sent to the backurl of an order.
+const (
+	// CallbackPrinted 打印成功。
+	CallbackPrinted = 1
+	// CallbackFailed 打印失败，订单过期或被清空。
+	CallbackFailed = 0
+)
+
+// WithCallbackURL sets the URL status callbacks are sent to for orders without a backurl.
+func WithCallbackURL(u string) Option {
+	return func(s *Server) {
+		s.callbackURL = u
+	}
+}
+
+// WithAutoCallback sends a status callback delay after an order is printed, expired or cleared.
+func WithAutoCallback(delay time.Duration) Option {
+	return func(s *Server) {
+		s.autoCallback = true
+		s.callbackDelay = delay
+	}
+}
+
+// signingKey returns the RSA key callbacks are signed with, generated on first use.
+func (s *Server) signingKey() (*rsa.PrivateKey, error) {
+	s.keyOnce.Do(func() {
+		s.key, s.keyErr = rsa.GenerateKey(rand.Reader, 2048)
+	})
+	return s.key, s.keyErr
+}
+
+// PublicKey returns the base64 DER (PKIX) public key that verifies callbacks, for feie.WithPublicKey.
+func (s *Server) PublicKey() (string, error) {
+	key, err := s.signingKey()
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// SignCallback returns the SHA256WithRSA sign of orderId=...&status=...&stime=..., base64 encoded.
+func (s *Server) SignCallback(orderID string, status int, stime int64) (string, error) {
+	key, err := s.signingKey()
+	if err != nil {
+		return "", err
+	}
+	content := "orderId=" + orderID + "&status=" + strconv.Itoa(status) + "&stime=" + strconv.FormatInt(stime, 10)
+	hashed := sha256.Sum256([]byte(content))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// SendCallback posts a signed status callback of the order to target, empty target means the
+// backurl of the order or WithCallbackURL. The status follows the state of the order.
+func (s *Server) SendCallback(ctx context.Context, orderID, target string) error {
+	s.mu.Lock()
+	o, found := s.orders[orderID]
+	var state OrderState
+	if found {
+		state = o.State
+		if target == "" {
+			target = o.BackURL
+		}
+	}
+	if target == "" {
+		target = s.callbackURL
+	}
+	now := s.now()
+	s.mu.Unlock()
+	if !found {
+		return fmt.Errorf("feietest: order %s not found", orderID)
+	}
+	if target == "" {
+		return fmt.Errorf("feietest: order %s has no callback url", orderID)
+	}
+	if state == OrderWaiting {
+		return fmt.Errorf("feietest: order %s is still waiting", orderID)
+	}
+	status := CallbackFailed
+	if state == OrderPrinted {
+		status = CallbackPrinted
+	}
+	return s.PostCallback(ctx, target, orderID, status, now.Unix())
+}
+
+// PostCallback posts a signed callback with any status to target, feieyun expects SUCCESS back.
+func (s *Server) PostCallback(ctx context.Context, target, orderID string, status int, stime int64) error {
+	sign, err := s.SignCallback(orderID, status, stime)
+	if err != nil {
+		return err
+	}
+	form := url.Values{
+		"orderId": {orderID},
+		"status":  {strconv.Itoa(status)},
+		"stime":   {strconv.FormatInt(stime, 10)},
+		"sign":    {sign},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "SUCCESS" {
+		return fmt.Errorf("feietest: callback to %s got %d %q", target, resp.StatusCode, body)
+	}
+	return nil
+}
+
+// CallbackErrors returns the errors of callbacks sent by WithAutoCallback.
+func (s *Server) CallbackErrors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error(nil), s.callbackErrs...)
+}
+
+// scheduleCallback sends the callback of the order after the delay when WithAutoCallback is set,
+// s.mu must be held.
+func (s *Server) scheduleCallback(o *Order) {
+	if s.closed || !s.autoCallback || (o.BackURL == "" && s.callbackURL == "") {
+		return
+	}
+	s.wg.Add(1)
+	go func(orderID string) {
+		defer s.wg.Done()
+		timer := time.NewTimer(s.callbackDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-s.done:
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-s.done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		if err := s.SendCallback(ctx, orderID, ""); err != nil && !errors.Is(err, context.Canceled) {
+			s.mu.Lock()
+			s.callbackErrs = append(s.callbackErrs, err)
+			s.mu.Unlock()
+		}
+	}(o.ID)
+}
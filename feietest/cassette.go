@@ -0,0 +1,206 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feietest
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/houseme/feie"
+)
+
+// Redacted replaces secrets in recorded interactions.
+const Redacted = "REDACTED"
+
+// ErrNoRecording is returned by the Replayer when a request has no recorded interaction.
+var ErrNoRecording = errors.New("feietest: no recording for request")
+
+var (
+	// volatileFields change on every call and are not matched on replay.
+	volatileFields = map[string]bool{
+		feie.UserField:    true,
+		feie.SysTimeField: true,
+		feie.SigField:     true,
+		feie.ExpiredField: true,
+	}
+
+	// phonePattern matches mainland China mobile numbers, such as the card number of a printer.
+	phonePattern = regexp.MustCompile(`(^|\D)1[3-9]\d{9}(\D|$)`)
+)
+
+// Interaction is one recorded request and response, stored as one JSON file in a cassette dir.
+type Interaction struct {
+	API      string            `json:"api"`
+	Request  map[string]string `json:"request"`
+	Response string            `json:"response"`
+}
+
+// redactForm returns a copy of the form with sig and phone numbers redacted.
+func redactForm(form map[string]string) map[string]string {
+	out := make(map[string]string, len(form))
+	for k, v := range form {
+		switch k {
+		case feie.SigField:
+			v = Redacted
+		case feie.PhoneNumField:
+			if v != "" {
+				v = Redacted
+			}
+		default:
+			v = redactPhones(v)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// redactPhones replaces mobile numbers in s.
+func redactPhones(s string) string {
+	return phonePattern.ReplaceAllString(s, "${1}"+Redacted+"${2}")
+}
+
+// matchKey returns the API name and the sorted non-volatile fields of a redacted form.
+func matchKey(form map[string]string) string {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		if !volatileFields[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(form[feie.APINameField])
+	for _, k := range keys {
+		b.WriteString("&" + k + "=" + form[k])
+	}
+	return b.String()
+}
+
+// fileName returns the cassette file name of the n-th interaction with the key.
+func fileName(api, key string, n int) string {
+	sum := sha1.Sum([]byte(key))
+	return fmt.Sprintf("%s-%s-%03d.json", api, hex.EncodeToString(sum[:4]), n)
+}
+
+// Recorder is a feie.Transport that forwards requests and writes every interaction to dir.
+// The UKEY never reaches the form; sig and phone numbers are redacted before writing.
+type Recorder struct {
+	dir   string
+	next  feie.Transport
+	mu    sync.Mutex
+	count map[string]int
+}
+
+// NewRecorder returns a Recorder writing to dir, next nil means feie.NewHTTPTransport(0).
+func NewRecorder(dir string, next feie.Transport) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if next == nil {
+		next = feie.NewHTTPTransport(0)
+	}
+	return &Recorder{dir: dir, next: next, count: make(map[string]int)}, nil
+}
+
+// Do implements feie.Transport.
+func (r *Recorder) Do(ctx context.Context, gateway string, form map[string]string) ([]byte, error) {
+	body, err := r.next.Do(ctx, gateway, form)
+	if err != nil {
+		return nil, err
+	}
+	redacted := redactForm(form)
+	key := matchKey(redacted)
+	r.mu.Lock()
+	n := r.count[key]
+	r.count[key]++
+	r.mu.Unlock()
+
+	content, err := json.MarshalIndent(&Interaction{
+		API:      form[feie.APINameField],
+		Request:  redacted,
+		Response: redactPhones(string(body)),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(r.dir, fileName(form[feie.APINameField], key, n)), content, 0o644); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Replayer is a feie.Transport answering from the interactions recorded in a dir.
+// Repeated identical requests replay the recordings in order, the last one is replayed
+// once they run out.
+type Replayer struct {
+	mu      sync.Mutex
+	records map[string][]*Interaction
+	next    map[string]int
+}
+
+// NewReplayer loads every interaction in dir.
+func NewReplayer(dir string) (*Replayer, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	r := &Replayer{records: make(map[string][]*Interaction), next: make(map[string]int)}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var in Interaction
+		if err = json.Unmarshal(content, &in); err != nil {
+			return nil, fmt.Errorf("feietest: cassette %s: %w", file, err)
+		}
+		key := matchKey(in.Request)
+		r.records[key] = append(r.records[key], &in)
+	}
+	return r, nil
+}
+
+// Do implements feie.Transport.
+func (r *Replayer) Do(_ context.Context, _ string, form map[string]string) ([]byte, error) {
+	key := matchKey(redactForm(form))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records := r.records[key]
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoRecording, key)
+	}
+	i := r.next[key]
+	if i >= len(records) {
+		i = len(records) - 1
+	} else {
+		r.next[key]++
+	}
+	return []byte(records[i].Response), nil
+}
@@ -0,0 +1,90 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feietest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/houseme/feie"
+)
+
+func TestRecorderReplayer(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srv := NewServer(WithAutoPrint(false))
+	srv.AddPrinter(DefaultUser, "sn1", "key1")
+
+	rec, err := NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := srv.Client(ctx, feie.WithTransport(rec))
+	if _, err = c.OpenPrinterEdit(ctx, &feie.PrinterEditReq{SN: "sn1", Name: "bar", PhoneNum: "13812345678"}); err != nil {
+		t.Fatal(err)
+	}
+	printResp, err := c.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn1", Content: "call 13812345678"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []bool
+	for i := 0; i < 2; i++ {
+		resp, err := c.OpenQueryOrderState(ctx, &feie.QueryOrderStateReq{OrderID: printResp.Data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, resp.Data)
+		srv.Flush()
+	}
+	srv.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 4 {
+		t.Fatalf("recorded %d files, want 4", len(files))
+	}
+	for _, file := range files {
+		content, _ := os.ReadFile(file)
+		if strings.Contains(string(content), "13812345678") || strings.Contains(string(content), DefaultUKey) {
+			t.Errorf("%s is not redacted: %s", file, content)
+		}
+	}
+
+	replay, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = feie.New(ctx, feie.WithUser("other"), feie.WithUserKey("other"), feie.WithTransport(replay))
+	replayed, err := c.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn1", Content: "call 13812345678"})
+	if err != nil || replayed.Data != printResp.Data {
+		t.Fatalf("replayed OpenPrintMsg() = %+v, %v", replayed, err)
+	}
+	for i, want := range append(states, states[len(states)-1]) {
+		resp, err := c.OpenQueryOrderState(ctx, &feie.QueryOrderStateReq{OrderID: printResp.Data})
+		if err != nil || resp.Data != want {
+			t.Errorf("replay %d: OpenQueryOrderState() = %+v, %v, want %v", i, resp, err, want)
+		}
+	}
+	if _, err = c.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn1", Content: "other"}); !errors.Is(err, ErrNoRecording) {
+		t.Errorf("OpenPrintMsg() without recording error = %v, want ErrNoRecording", err)
+	}
+}
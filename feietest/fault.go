@@ -0,0 +1,163 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feietest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/houseme/feie"
+)
+
+// Fault is a scripted response of the fake gateway, matched by API name and SN.
+type Fault struct {
+	// API limits the fault to one API, such as "Open_printMsg", empty matches every API.
+	API string
+	// SN limits the fault to requests for one printer, empty matches every printer.
+	SN string
+	// Count is how many matching requests the fault applies to, 0 applies it until removed.
+	Count int
+
+	// Latency delays the response.
+	Latency time.Duration
+	// Timeout closes the connection without responding, after Latency.
+	Timeout bool
+	// HTTPStatus responds with the status code and no body, such as http.StatusBadGateway.
+	HTTPStatus int
+	// EmptyBody responds 200 with an empty body.
+	EmptyBody bool
+	// Malformed responds 200 with a body that is not valid JSON.
+	Malformed bool
+	// Ret and Msg respond with a feieyun error instead of calling the API.
+	Ret int
+	Msg string
+}
+
+// faultEntry is an injected fault and the number of requests it applied to.
+type faultEntry struct {
+	id    int
+	fault Fault
+	hits  int
+}
+
+// Inject adds a fault, faults are matched in the order injected. The returned func removes it.
+func (s *Server) Inject(f Fault) (remove func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faultID++
+	id := s.faultID
+	s.faults = append(s.faults, &faultEntry{id: id, fault: f})
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.removeFault(id)
+	}
+}
+
+// ResetFaults removes every injected fault.
+func (s *Server) ResetFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// removeFault removes the fault by ID, s.mu must be held.
+func (s *Server) removeFault(id int) {
+	for i, e := range s.faults {
+		if e.id == id {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			return
+		}
+	}
+}
+
+// matchFault returns the first fault matching the request and counts the hit.
+func (s *Server) matchFault(form map[string]string) (Fault, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.faults {
+		if e.fault.API != "" && e.fault.API != form[feie.APINameField] {
+			continue
+		}
+		if e.fault.SN != "" && e.fault.SN != form[feie.SNField] {
+			continue
+		}
+		e.hits++
+		if e.fault.Count > 0 && e.hits >= e.fault.Count {
+			s.removeFault(e.id)
+		}
+		return e.fault, true
+	}
+	return Fault{}, false
+}
+
+// applyFault applies latency and transport faults, it reports whether the response was written.
+func (s *Server) applyFault(w http.ResponseWriter, r *http.Request, f Fault) bool {
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return true
+		case <-s.done:
+			timer.Stop()
+		}
+	}
+	switch {
+	case f.Timeout:
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				_ = conn.Close()
+				return true
+			}
+		}
+		w.WriteHeader(http.StatusGatewayTimeout)
+	case f.HTTPStatus != 0:
+		w.WriteHeader(f.HTTPStatus)
+	case f.EmptyBody:
+		w.WriteHeader(http.StatusOK)
+	case f.Malformed:
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		_, _ = w.Write([]byte(`{"msg":"ok","ret":0,"data":`))
+	default:
+		return false
+	}
+	return true
+}
+
+// SetStatusAfter changes the status of a printer after d, such as a printer going offline mid-test.
+func (s *Server) SetStatusAfter(sn string, status feie.PrinterStatus, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			_ = s.SetStatus(sn, status)
+		case <-s.done:
+		}
+	}()
+}
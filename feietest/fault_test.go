@@ -0,0 +1,152 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feietest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/houseme/feie"
+)
+
+func TestServer_Inject(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddPrinter(DefaultUser, "sn1", "key1")
+	srv.AddPrinter(DefaultUser, "sn2", "key2")
+	c := srv.Client(ctx)
+
+	tests := []struct {
+		name    string
+		fault   Fault
+		wantErr bool
+		wantRet int
+	}{
+		{name: "5xx", fault: Fault{HTTPStatus: http.StatusBadGateway}, wantErr: true},
+		{name: "empty body", fault: Fault{EmptyBody: true}, wantErr: true},
+		{name: "malformed", fault: Fault{Malformed: true}, wantErr: true},
+		{name: "timeout", fault: Fault{Timeout: true}, wantErr: true},
+		{name: "ret", fault: Fault{Ret: RetPrinterNotFound, Msg: "错误"}, wantRet: RetPrinterNotFound},
+		{name: "latency", fault: Fault{Latency: 20 * time.Millisecond}, wantRet: RetOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fault.API, tt.fault.SN = "Open_printMsg", "sn1"
+			remove := srv.Inject(tt.fault)
+			defer remove()
+			resp, err := c.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn1", Content: "x"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenPrintMsg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && resp.Ret != tt.wantRet {
+				t.Errorf("OpenPrintMsg() ret = %d, want %d", resp.Ret, tt.wantRet)
+			}
+			other, err := c.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn2", Content: "x"})
+			if err != nil || other.Ret != RetOK {
+				t.Errorf("OpenPrintMsg() on another printer = %+v, %v", other, err)
+			}
+		})
+	}
+
+	srv.Inject(Fault{API: "Open_queryPrinterStatus", Ret: -2, Msg: "参数错误", Count: 1})
+	for i, want := range []int{-2, RetOK} {
+		resp, err := c.OpenQueryPrinterStatus(ctx, &feie.QueryPrinterStatusReq{SN: "sn1"})
+		if err != nil || resp.Ret != want {
+			t.Errorf("call %d: OpenQueryPrinterStatus() = %+v, %v, want ret %d", i, resp, err, want)
+		}
+	}
+}
+
+func TestServer_Callback(t *testing.T) {
+	ctx := context.Background()
+	received := make(chan *feie.AsyncPrinterResultReq, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, _ := strconv.Atoi(r.PostFormValue("status"))
+		stime, _ := strconv.Atoi(r.PostFormValue("stime"))
+		received <- &feie.AsyncPrinterResultReq{
+			OrderID: r.PostFormValue("orderId"),
+			Sign:    r.PostFormValue("sign"),
+			Status:  status,
+			Stime:   stime,
+		}
+		_, _ = w.Write([]byte("SUCCESS"))
+	}))
+	defer receiver.Close()
+
+	srv := NewServer(WithAutoCallback(10 * time.Millisecond))
+	defer srv.Close()
+	srv.AddPrinter(DefaultUser, "sn1", "key1")
+	publicKey, err := srv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := srv.Client(ctx, feie.WithPublicKey(publicKey))
+
+	resp, err := c.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn1", Content: "x", BackURL: receiver.URL})
+	if err != nil || resp.Ret != RetOK {
+		t.Fatalf("OpenPrintMsg() = %+v, %v", resp, err)
+	}
+	var req *feie.AsyncPrinterResultReq
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no callback received")
+	}
+	result, err := c.AsyncPrinterResult(ctx, req)
+	if err != nil || !result.VerifySign || result.OrderID != resp.Data || result.Status != CallbackPrinted {
+		t.Fatalf("AsyncPrinterResult() = %+v, %v", result, err)
+	}
+
+	if err = srv.SendCallback(ctx, resp.Data, ""); err != nil {
+		t.Fatalf("SendCallback() error = %v", err)
+	}
+	<-received
+	if errs := srv.CallbackErrors(); len(errs) != 0 {
+		t.Errorf("CallbackErrors() = %v", errs)
+	}
+}
+
+func TestServer_CloseWhilePrinting(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(WithAutoCallback(time.Millisecond))
+	srv.AddPrinter(DefaultUser, "sn1", "key1")
+	c := srv.Client(ctx)
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				_, _ = c.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn1", Content: "x", BackURL: "http://127.0.0.1:1/"})
+				srv.SetStatusAfter("sn1", feie.PrinterStatusOnline, time.Millisecond)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	srv.Close()
+	close(done)
+}
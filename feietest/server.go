@@ -0,0 +1,637 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+// Package feietest provides an in-process fake of the feieyun gateway for integration tests.
+//
+//	srv := feietest.NewServer()
+//	defer srv.Close()
+//	srv.AddPrinter(feietest.DefaultUser, "816501678", "key")
+//	client := srv.Client(ctx)
+package feietest
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/houseme/feie"
+)
+
+const (
+	// DefaultUser is the user registered by NewServer.
+	DefaultUser = "feietest"
+	// DefaultUKey is the UKEY of DefaultUser.
+	DefaultUKey = "feietest-ukey"
+
+	// MaxContentBytes is the maximum size of the content of a print request.
+	// 打印内容,不能超过5000字节
+	MaxContentBytes = 5000
+	// MaxTimes is the maximum number of copies of a print request.
+	MaxTimes = 10
+
+	orderIDTimeLayout = "20060102150405"
+	dateLayout        = "2006-01-02"
+)
+
+// Error codes returned by the fake gateway, in the style of feieyun.
+const (
+	// RetOK is returned on success.
+	RetOK = 0
+	// RetParamError 参数错误，如缺少必填参数、打印内容过长或过期时间不合法。
+	RetParamError = -2
+	// RetSignError 签名错误或用户未注册。
+	RetSignError = -3
+	// RetUnknownAPI 接口名称错误。
+	RetUnknownAPI = -4
+	// RetPrinterNotFound 打印机编号和用户不匹配或未添加。
+	RetPrinterNotFound = 1002
+	// RetOrderNotFound 订单编号不存在。
+	RetOrderNotFound = 1003
+)
+
+// OrderState is the state of an order in the fake gateway.
+type OrderState string
+
+const (
+	// OrderWaiting is an order queued on its printer.
+	OrderWaiting OrderState = "waiting"
+	// OrderPrinted is an order the printer printed.
+	OrderPrinted OrderState = "printed"
+	// OrderExpired is an order dropped because its Expired time passed before printing.
+	OrderExpired OrderState = "expired"
+	// OrderCleared is an order removed by Open_delPrinterSqs.
+	OrderCleared OrderState = "cleared"
+)
+
+// Printer is a printer registered in the fake gateway.
+type Printer struct {
+	User     string
+	SN       string
+	Key      string
+	Name     string
+	PhoneNum string
+	Status   feie.PrinterStatus
+}
+
+// Order is an order submitted to the fake gateway.
+type Order struct {
+	ID        string
+	User      string
+	SN        string
+	API       string
+	Content   string
+	Img       string
+	Times     int
+	BackURL   string
+	Expired   time.Time
+	CreatedAt time.Time
+	PrintedAt time.Time
+	State     OrderState
+}
+
+// Option configures the fake gateway.
+type Option func(s *Server)
+
+// WithUser registers another user and its UKEY.
+func WithUser(user, ukey string) Option {
+	return func(s *Server) {
+		s.users[user] = ukey
+	}
+}
+
+// WithClock sets the time source, default time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		if now != nil {
+			s.now = now
+		}
+	}
+}
+
+// WithAutoPrint sets whether healthy printers print their queue on every request, default true.
+// When disabled, orders stay waiting until Flush is called.
+func WithAutoPrint(auto bool) Option {
+	return func(s *Server) {
+		s.autoPrint = auto
+	}
+}
+
+// Server is a fake feieyun gateway backed by an in-memory printer registry and job queue.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	now       func() time.Time
+	autoPrint bool
+	users     map[string]string
+	printers  map[string]*Printer
+	orders    map[string]*Order
+	queues    map[string][]*Order
+	seq       int64
+
+	faults  []*faultEntry
+	faultID int
+
+	callbackURL   string
+	callbackDelay time.Duration
+	autoCallback  bool
+	callbackErrs  []error
+	keyOnce       sync.Once
+	key           *rsa.PrivateKey
+	keyErr        error
+	httpClient    *http.Client
+	wg            sync.WaitGroup // background callbacks and faults, Added under mu unless closed
+	closed        bool
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+// NewServer starts a fake gateway with DefaultUser registered.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		now:       time.Now,
+		autoPrint: true,
+		users:     map[string]string{DefaultUser: DefaultUKey},
+		printers:  make(map[string]*Printer),
+		orders:    make(map[string]*Order),
+		queues:    make(map[string][]*Order),
+		seq:       1419533539,
+
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Close stops pending callbacks and faults, then shuts the server down.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		// no handler starts a background task once closed is set, so Wait does not race with Add.
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.done)
+		s.wg.Wait()
+		s.Server.Close()
+	})
+}
+
+// Client returns a client of DefaultUser pointed at the fake gateway, opts are applied last.
+func (s *Server) Client(ctx context.Context, opts ...feie.Option) *feie.Client {
+	return feie.New(ctx, append([]feie.Option{
+		feie.WithUser(DefaultUser),
+		feie.WithUserKey(DefaultUKey),
+		feie.WithGateway(s.URL),
+	}, opts...)...)
+}
+
+// AddPrinter registers an online printer for user, as if added in the feieyun console.
+func (s *Server) AddPrinter(user, sn, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.printers[sn] = &Printer{User: user, SN: sn, Key: key, Status: feie.PrinterStatusOnline}
+}
+
+// SetStatus changes the status of a printer, a printer becoming healthy prints its queue.
+func (s *Server) SetStatus(sn string, status feie.PrinterStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.printers[sn]
+	if !ok {
+		return fmt.Errorf("feietest: printer %s not found", sn)
+	}
+	p.Status = status
+	if s.autoPrint {
+		s.process(sn)
+	}
+	return nil
+}
+
+// Flush lets every healthy printer print its queue and drops expired orders.
+func (s *Server) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sn := range s.queues {
+		s.process(sn)
+	}
+}
+
+// Printer returns a copy of the printer.
+func (s *Server) Printer(sn string) (Printer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.printers[sn]
+	if !ok {
+		return Printer{}, false
+	}
+	return *p, true
+}
+
+// Order returns a copy of the order.
+func (s *Server) Order(id string) (Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[id]
+	if !ok {
+		return Order{}, false
+	}
+	return *o, true
+}
+
+// Orders returns copies of the orders sent to sn, oldest first.
+func (s *Server) Orders(sn string) []Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := make([]Order, 0)
+	for _, o := range s.orders {
+		if o.SN == sn {
+			orders = append(orders, *o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	return orders
+}
+
+// Queue returns the IDs of the orders waiting on sn.
+func (s *Server) Queue(sn string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.queues[sn]))
+	for _, o := range s.queues[sn] {
+		ids = append(ids, o.ID)
+	}
+	return ids
+}
+
+// process prints the queue of sn when the printer is healthy and drops expired orders, s.mu must be held.
+func (s *Server) process(sn string) {
+	var (
+		now     = s.now()
+		p       = s.printers[sn]
+		healthy = p != nil && p.Status.Healthy()
+		queue   = s.queues[sn][:0]
+	)
+	for _, o := range s.queues[sn] {
+		switch {
+		case !o.Expired.IsZero() && now.After(o.Expired):
+			o.State = OrderExpired
+			s.scheduleCallback(o)
+		case healthy:
+			o.State = OrderPrinted
+			o.PrintedAt = now
+			s.scheduleCallback(o)
+		default:
+			queue = append(queue, o)
+		}
+	}
+	if len(queue) == 0 {
+		delete(s.queues, sn)
+		return
+	}
+	s.queues[sn] = queue
+}
+
+// response is the response body of the gateway.
+type response struct {
+	Msg                string      `json:"msg"`
+	Ret                int         `json:"ret"`
+	Data               interface{} `json:"data"`
+	ServerExecutedTime int64       `json:"serverExecutedTime"`
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	form, err := parseForm(r)
+	if err != nil {
+		writeJSON(w, start, fail(RetParamError, "参数错误 : "+err.Error()))
+		return
+	}
+	if f, found := s.matchFault(form); found {
+		if s.applyFault(w, r, f) {
+			return
+		}
+		if f.Ret != RetOK {
+			writeJSON(w, start, fail(f.Ret, f.Msg))
+			return
+		}
+	}
+	writeJSON(w, start, s.handle(form))
+}
+
+// writeJSON writes the response body.
+func writeJSON(w http.ResponseWriter, start time.Time, resp *response) {
+	resp.ServerExecutedTime = time.Since(start).Milliseconds()
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// handle checks the signature and calls the API.
+func (s *Server) handle(form map[string]string) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := form[feie.UserField]
+	ukey, ok := s.users[user]
+	if !ok {
+		return fail(RetSignError, "参数错误 : 该帐号未注册.")
+	}
+	if _, err := strconv.ParseInt(form[feie.SysTimeField], 10, 64); err != nil {
+		return fail(RetParamError, "参数错误 : stime不正确.")
+	}
+	if form[feie.SigField] != Sign(user, ukey, form[feie.SysTimeField]) {
+		return fail(RetSignError, "参数错误 : 签名错误.")
+	}
+	if s.autoPrint {
+		for sn := range s.queues {
+			s.process(sn)
+		}
+	}
+	switch api := form[feie.APINameField]; api {
+	case "Open_printerAddlist":
+		return s.printerAddList(user, form)
+	case "Open_printerDelList":
+		return s.printerDelList(user, form)
+	case "Open_printMsg", "Open_printLabelMsg":
+		return s.print(user, api, form)
+	case "Open_printerEdit":
+		return s.printerEdit(user, form)
+	case "Open_delPrinterSqs":
+		return s.delPrinterSqs(user, form)
+	case "Open_queryOrderState":
+		return s.queryOrderState(user, form)
+	case "Open_queryOrderInfoByDate":
+		return s.queryOrderInfoByDate(user, form)
+	case "Open_queryPrinterStatus":
+		return s.queryPrinterStatus(user, form)
+	default:
+		return fail(RetUnknownAPI, "参数错误 : 接口名称不正确.")
+	}
+}
+
+// Sign returns the sig of user+ukey+stime, the same as the client sends.
+func Sign(user, ukey, stime string) string {
+	sum := sha1.Sum([]byte(user + ukey + stime))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseForm parses a multipart or url-encoded form into single values.
+func parseForm(r *http.Request) (map[string]string, error) {
+	if r.Method != http.MethodPost {
+		return nil, errors.New("method must be POST")
+	}
+	var values map[string][]string
+	switch err := r.ParseMultipartForm(1 << 20); {
+	case err == nil:
+		values = r.MultipartForm.Value
+	case errors.Is(err, http.ErrNotMultipart):
+		if err = r.ParseForm(); err != nil {
+			return nil, err
+		}
+		values = r.PostForm
+	default:
+		return nil, err
+	}
+	form := make(map[string]string, len(values))
+	for k, v := range values {
+		if len(v) > 0 {
+			form[k] = v[0]
+		}
+	}
+	return form, nil
+}
+
+func ok(data interface{}) *response {
+	return &response{Msg: "ok", Ret: RetOK, Data: data}
+}
+
+func fail(ret int, msg string) *response {
+	return &response{Msg: msg, Ret: ret}
+}
+
+// printer returns the printer of user, or the error response.
+func (s *Server) printer(user, sn string) (*Printer, *response) {
+	if strings.TrimSpace(sn) == "" {
+		return nil, fail(RetParamError, "参数错误 : 参数sn不能为空.")
+	}
+	p, found := s.printers[sn]
+	if !found || p.User != user {
+		return nil, fail(RetPrinterNotFound, "错误：打印机编号"+sn+"和用户不匹配.")
+	}
+	return p, nil
+}
+
+func (s *Server) printerAddList(user string, form map[string]string) *response {
+	content := strings.TrimSpace(form[feie.PrinterContentField])
+	if content == "" {
+		return fail(RetParamError, "参数错误 : 参数printerContent不能为空.")
+	}
+	lines := strings.Split(content, "\n")
+	if len(lines) > feie.MaxPrinterAddList {
+		return fail(RetParamError, fmt.Sprintf("参数错误 : 每次最多添加%d台.", feie.MaxPrinterAddList))
+	}
+	okList, noList := make([]string, 0), make([]string, 0)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		fields := strings.Split(line, "#")
+		for len(fields) < 4 {
+			fields = append(fields, "")
+		}
+		sn, key := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+		switch p, found := s.printers[sn]; {
+		case sn == "" || key == "":
+			noList = append(noList, line+"  （错误：识别码不正确）")
+		case found && p.User == user:
+			noList = append(noList, line+"  （错误：已被添加过）")
+		case found:
+			noList = append(noList, line+"  （错误：已被其他帐号添加）")
+		default:
+			s.printers[sn] = &Printer{
+				User:     user,
+				SN:       sn,
+				Key:      key,
+				Name:     strings.TrimSpace(fields[2]),
+				PhoneNum: strings.TrimSpace(fields[3]),
+				Status:   feie.PrinterStatusOnline,
+			}
+			okList = append(okList, line)
+		}
+	}
+	return ok(map[string][]string{"ok": okList, "no": noList})
+}
+
+func (s *Server) printerDelList(user string, form map[string]string) *response {
+	snList := strings.TrimSpace(form[feie.SNListField])
+	if snList == "" {
+		return fail(RetParamError, "参数错误 : 参数snlist不能为空.")
+	}
+	okList, noList := make([]string, 0), make([]string, 0)
+	for _, sn := range strings.Split(snList, "-") {
+		if p, found := s.printers[sn]; !found || p.User != user {
+			noList = append(noList, sn+"用户UID不匹配")
+			continue
+		}
+		delete(s.printers, sn)
+		s.clearQueue(sn)
+		okList = append(okList, sn+"成功")
+	}
+	return ok(map[string][]string{"ok": okList, "no": noList})
+}
+
+func (s *Server) print(user, api string, form map[string]string) *response {
+	sn := form[feie.SNField]
+	if _, errResp := s.printer(user, sn); errResp != nil {
+		return errResp
+	}
+	content := form[feie.ContentField]
+	if content == "" {
+		return fail(RetParamError, "参数错误 : 参数content不能为空.")
+	}
+	if len(content) > MaxContentBytes {
+		return fail(RetParamError, fmt.Sprintf("参数错误 : 打印内容不能超过%d字节.", MaxContentBytes))
+	}
+	now := s.now()
+	order := &Order{
+		User:      user,
+		SN:        sn,
+		API:       api,
+		Content:   content,
+		Img:       form[feie.ImgField],
+		Times:     1,
+		BackURL:   form[feie.BackURLField],
+		CreatedAt: now,
+		State:     OrderWaiting,
+	}
+	if v := form[feie.TimesField]; v != "" {
+		times, err := strconv.Atoi(v)
+		if err != nil || times < 1 || times > MaxTimes {
+			return fail(RetParamError, fmt.Sprintf("参数错误 : 打印联数范围为1-%d.", MaxTimes))
+		}
+		order.Times = times
+	}
+	if v := form[feie.ExpiredField]; v != "" {
+		expired, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || expired <= now.Unix() || expired > now.Add(24*time.Hour).Unix() {
+			return fail(RetParamError, "参数错误 : 订单失效时间取值范围为：当前时间<订单失效时间≤24小时后.")
+		}
+		order.Expired = time.Unix(expired, 0)
+	}
+	s.seq++
+	order.ID = sn + "_" + now.In(feie.Shanghai).Format(orderIDTimeLayout) + "_" + strconv.FormatInt(s.seq, 10)
+	s.orders[order.ID] = order
+	s.queues[sn] = append(s.queues[sn], order)
+	if s.autoPrint {
+		s.process(sn)
+	}
+	return ok(order.ID)
+}
+
+func (s *Server) printerEdit(user string, form map[string]string) *response {
+	p, errResp := s.printer(user, form[feie.SNField])
+	if errResp != nil {
+		return errResp
+	}
+	p.Name = form[feie.NameField]
+	if v, found := form[feie.PhoneNumField]; found {
+		p.PhoneNum = v
+	}
+	return ok(true)
+}
+
+func (s *Server) delPrinterSqs(user string, form map[string]string) *response {
+	sn := form[feie.SNField]
+	if _, errResp := s.printer(user, sn); errResp != nil {
+		return errResp
+	}
+	s.clearQueue(sn)
+	return ok(true)
+}
+
+// clearQueue removes the waiting orders of sn, s.mu must be held.
+func (s *Server) clearQueue(sn string) {
+	for _, o := range s.queues[sn] {
+		o.State = OrderCleared
+		s.scheduleCallback(o)
+	}
+	delete(s.queues, sn)
+}
+
+func (s *Server) queryOrderState(user string, form map[string]string) *response {
+	id := form[feie.OrderIDField]
+	if strings.TrimSpace(id) == "" {
+		return fail(RetParamError, "参数错误 : 参数orderid不能为空.")
+	}
+	o, found := s.orders[id]
+	if !found || o.User != user {
+		return fail(RetOrderNotFound, "错误：订单编号不存在.")
+	}
+	return ok(o.State == OrderPrinted)
+}
+
+func (s *Server) queryOrderInfoByDate(user string, form map[string]string) *response {
+	sn := form[feie.SNField]
+	if _, errResp := s.printer(user, sn); errResp != nil {
+		return errResp
+	}
+	date := form[feie.DateField]
+	if _, err := time.ParseInLocation(dateLayout, date, feie.Shanghai); err != nil {
+		return fail(RetParamError, "参数错误 : 日期格式不正确.")
+	}
+	var printed, waiting int
+	for _, o := range s.orders {
+		if o.SN != sn || o.CreatedAt.In(feie.Shanghai).Format(dateLayout) != date {
+			continue
+		}
+		switch o.State {
+		case OrderPrinted:
+			printed++
+		case OrderWaiting:
+			waiting++
+		}
+	}
+	return ok(map[string]int{"print": printed, "waiting": waiting})
+}
+
+func (s *Server) queryPrinterStatus(user string, form map[string]string) *response {
+	p, errResp := s.printer(user, form[feie.SNField])
+	if errResp != nil {
+		return errResp
+	}
+	switch p.Status {
+	case feie.PrinterStatusOnline:
+		return ok("在线，工作状态正常。")
+	case feie.PrinterStatusAbnormal:
+		return ok("在线，工作状态不正常。")
+	default:
+		return ok("离线。")
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feietest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/houseme/feie"
+)
+
+func TestServer_PrintAndQuery(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(WithAutoPrint(false))
+	defer srv.Close()
+	c := srv.Client(ctx)
+
+	addResp, err := c.OpenPrinterAddList(ctx, &feie.PrinterAddReq{Printers: []feie.PrinterSpec{
+		{SN: "sn1", Key: "key1", Remark: "kitchen"},
+		{SN: "sn2", Key: "key2"},
+	}})
+	if err != nil || addResp.Ret != RetOK {
+		t.Fatalf("OpenPrinterAddList() = %+v, %v", addResp, err)
+	}
+	if results := addResp.Data.AddResults(); len(results) != 2 || !results[0].OK || !results[1].OK {
+		t.Fatalf("AddResults() = %+v", results)
+	}
+	if p, _ := srv.Printer("sn1"); p.Name != "kitchen" || p.User != DefaultUser {
+		t.Errorf("Printer() = %+v", p)
+	}
+
+	printResp, err := c.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn1", Content: "hello", Times: 2})
+	if err != nil || printResp.Ret != RetOK {
+		t.Fatalf("OpenPrintMsg() = %+v, %v", printResp, err)
+	}
+	id, err := feie.ParseOrderID(printResp.Data)
+	if err != nil || id.SN() != "sn1" {
+		t.Fatalf("ParseOrderID(%q) = %v, %v", printResp.Data, id, err)
+	}
+	if o, _ := srv.Order(printResp.Data); o.Times != 2 || o.State != OrderWaiting {
+		t.Errorf("Order() = %+v", o)
+	}
+
+	stateResp, err := c.OpenQueryOrderState(ctx, &feie.QueryOrderStateReq{OrderID: printResp.Data})
+	if err != nil || stateResp.Data {
+		t.Fatalf("OpenQueryOrderState() before flush = %+v, %v", stateResp, err)
+	}
+	srv.Flush()
+	stateResp, err = c.OpenQueryOrderState(ctx, &feie.QueryOrderStateReq{OrderID: printResp.Data})
+	if err != nil || !stateResp.Data {
+		t.Fatalf("OpenQueryOrderState() after flush = %+v, %v", stateResp, err)
+	}
+
+	infoResp, err := c.OpenQueryOrderInfoByDate(ctx, &feie.QueryOrderInfoByDateReq{SN: "sn1", Date: feie.FormatDate(time.Now())})
+	if err != nil || infoResp.Data == nil || infoResp.Data.Print != 1 || infoResp.Data.Waiting != 0 {
+		t.Fatalf("OpenQueryOrderInfoByDate() = %+v, %v", infoResp, err)
+	}
+
+	delResp, err := c.OpenPrinterDelList(ctx, &feie.PrinterDelReq{SNList: "sn2-sn9"})
+	if err != nil || delResp.Ret != RetOK {
+		t.Fatalf("OpenPrinterDelList() = %+v, %v", delResp, err)
+	}
+	if results := delResp.Data.DelResults([]string{"sn2", "sn9"}); !results[0].OK || results[1].OK {
+		t.Errorf("DelResults() = %+v", results)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddPrinter(DefaultUser, "sn1", "key1")
+	c := srv.Client(ctx)
+
+	tests := []struct {
+		name string
+		req  *feie.PrintMsgReq
+		want int
+	}{
+		{name: "unknown printer", req: &feie.PrintMsgReq{SN: "sn9", Content: "x"}, want: RetPrinterNotFound},
+		{name: "empty content", req: &feie.PrintMsgReq{SN: "sn1"}, want: RetParamError},
+		{name: "too many times", req: &feie.PrintMsgReq{SN: "sn1", Content: "x", Times: MaxTimes + 1}, want: RetParamError},
+		{name: "expired too late", req: &feie.PrintMsgReq{SN: "sn1", Content: "x", Expired: time.Now().Add(48 * time.Hour).Unix()}, want: RetParamError},
+		{name: "ok", req: &feie.PrintMsgReq{SN: "sn1", Content: "x"}, want: RetOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := c.OpenPrintMsg(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("OpenPrintMsg() error = %v", err)
+			}
+			if resp.Ret != tt.want {
+				t.Errorf("OpenPrintMsg() ret = %d (%s), want %d", resp.Ret, resp.Msg, tt.want)
+			}
+		})
+	}
+
+	bad := srv.Client(ctx, feie.WithUserKey("wrong"))
+	resp, err := bad.OpenQueryPrinterStatus(ctx, &feie.QueryPrinterStatusReq{SN: "sn1"})
+	if err != nil || resp.Ret != RetSignError {
+		t.Errorf("OpenQueryPrinterStatus() with wrong ukey = %+v, %v", resp, err)
+	}
+}
+
+func TestServer_StatusAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	srv := NewServer(WithClock(func() time.Time { return now }))
+	defer srv.Close()
+	srv.AddPrinter(DefaultUser, "sn1", "key1")
+	c := srv.Client(ctx)
+
+	if err := srv.SetStatus("sn1", feie.PrinterStatusAbnormal); err != nil {
+		t.Fatal(err)
+	}
+	statusResp, err := c.OpenQueryPrinterStatus(ctx, &feie.QueryPrinterStatusReq{SN: "sn1"})
+	if err != nil || statusResp.Status() != feie.PrinterStatusAbnormal {
+		t.Fatalf("OpenQueryPrinterStatus() = %+v, %v", statusResp, err)
+	}
+
+	expiring, _ := c.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn1", Content: "a", Expired: now.Add(time.Minute).Unix()})
+	cleared, _ := c.OpenPrintMsg(ctx, &feie.PrintMsgReq{SN: "sn1", Content: "b"})
+	if got := srv.Queue("sn1"); len(got) != 2 {
+		t.Fatalf("Queue() = %v, want 2 orders", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	srv.Flush()
+	if o, _ := srv.Order(expiring.Data); o.State != OrderExpired {
+		t.Errorf("expired order state = %s", o.State)
+	}
+	if _, err = c.OpenDelPrinterSQS(ctx, &feie.DelPrinterSQSReq{SN: "sn1"}); err != nil {
+		t.Fatal(err)
+	}
+	if o, _ := srv.Order(cleared.Data); o.State != OrderCleared {
+		t.Errorf("cleared order state = %s", o.State)
+	}
+
+	if _, err = c.OpenPrinterEdit(ctx, &feie.PrinterEditReq{SN: "sn1", Name: "bar", PhoneNum: "13800000000"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := srv.Printer("sn1"); p.Name != "bar" || p.PhoneNum != "13800000000" {
+		t.Errorf("Printer() after edit = %+v", p)
+	}
+}
@@ -0,0 +1,447 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrClearPhoneNum is the result of an edit clearing the phone number, Open_printerEdit
+// omits an empty phoneNum so the printer keeps its old number.
+var ErrClearPhoneNum = errors.New("feie: Open_printerEdit cannot clear phoneNum")
+
+// FleetPrinter is one printer of a fleet inventory.
+type FleetPrinter struct {
+	SN       string `json:"sn" yaml:"sn" description:"打印机编号。"`
+	Key      string `json:"key" yaml:"key" description:"打印机识别码。"`
+	Name     string `json:"name,omitempty" yaml:"name,omitempty" description:"打印机备注名称。"`
+	PhoneNum string `json:"phoneNum,omitempty" yaml:"phoneNum,omitempty" description:"打印机流量卡号码。"`
+}
+
+// spec returns the PrinterSpec used to add the printer.
+func (p FleetPrinter) spec() PrinterSpec {
+	return PrinterSpec{SN: p.SN, Key: p.Key, Remark: p.Name, CardNumber: p.PhoneNum}
+}
+
+// FleetInventory is the desired set of printers, usually kept in git as YAML or JSON.
+type FleetInventory struct {
+	Printers []FleetPrinter `json:"printers" yaml:"printers"`
+}
+
+// strictJSON decodes JSON rejecting unknown keys, like yaml.UnmarshalStrict.
+var strictJSON = sonic.Config{DisallowUnknownFields: true}.Froze()
+
+// LoadFleetInventory loads the inventory from a .yaml, .yml or .json file, an unknown key is an error.
+func LoadFleetInventory(path string) (*FleetInventory, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	inv := &FleetInventory{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(content, inv)
+	case ".json":
+		err = strictJSON.Unmarshal(content, inv)
+	default:
+		err = fmt.Errorf("feie: unsupported inventory format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return inv, inv.Validate()
+}
+
+// Validate checks every printer and rejects duplicated SNs.
+func (inv *FleetInventory) Validate() error {
+	seen := make(map[string]struct{}, len(inv.Printers))
+	for _, p := range inv.Printers {
+		if err := p.spec().Validate(); err != nil {
+			return err
+		}
+		if _, ok := seen[p.SN]; ok {
+			return fmt.Errorf("feie: duplicated printer sn %s", p.SN)
+		}
+		seen[p.SN] = struct{}{}
+	}
+	return nil
+}
+
+// FleetState is the set of printers last applied by FleetSync.
+type FleetState struct {
+	Printers  map[string]FleetPrinter `json:"printers"`
+	UpdatedAt time.Time               `json:"updatedAt"`
+}
+
+// LoadFleetState loads the state file, a missing file is an empty state.
+func LoadFleetState(path string) (*FleetState, error) {
+	state := &FleetState{Printers: make(map[string]FleetPrinter)}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = sonic.Unmarshal(content, state); err != nil {
+		return nil, err
+	}
+	if state.Printers == nil {
+		state.Printers = make(map[string]FleetPrinter)
+	}
+	return state, nil
+}
+
+// Save writes the state file atomically.
+func (s *FleetState) Save(path string) error {
+	content, err := sonic.ConfigStd.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, content)
+}
+
+// writeFileAtomic writes content to a temporary file next to path and renames it over path.
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// FleetActionType is the type of FleetAction.
+type FleetActionType string
+
+const (
+	// FleetAdd adds the printer with Open_printerAddlist.
+	FleetAdd FleetActionType = "add"
+	// FleetEdit edits the name or phone number of the printer with Open_printerEdit.
+	FleetEdit FleetActionType = "edit"
+	// FleetDelete deletes the printer with Open_printerDelList.
+	FleetDelete FleetActionType = "delete"
+)
+
+// FleetAction is one step of a FleetPlan.
+type FleetAction struct {
+	Type FleetActionType `json:"type"`
+	// Printer is the desired printer, or the applied one for FleetDelete.
+	Printer FleetPrinter `json:"printer"`
+	// Previous is the applied printer for FleetEdit.
+	Previous *FleetPrinter `json:"previous,omitempty"`
+}
+
+// String returns the diff line of the action, the printer key is never printed.
+func (a FleetAction) String() string {
+	switch a.Type {
+	case FleetAdd:
+		return fmt.Sprintf("+ add    %s name=%q phoneNum=%q", a.Printer.SN, a.Printer.Name, a.Printer.PhoneNum)
+	case FleetEdit:
+		var changes []string
+		if a.Previous.Name != a.Printer.Name {
+			changes = append(changes, fmt.Sprintf("name: %q -> %q", a.Previous.Name, a.Printer.Name))
+		}
+		if a.Previous.PhoneNum != a.Printer.PhoneNum {
+			changes = append(changes, fmt.Sprintf("phoneNum: %q -> %q", a.Previous.PhoneNum, a.Printer.PhoneNum))
+		}
+		return fmt.Sprintf("~ edit   %s %s", a.Printer.SN, strings.Join(changes, ", "))
+	default:
+		return fmt.Sprintf("- delete %s", a.Printer.SN)
+	}
+}
+
+// FleetPlan is the list of actions that turns the applied state into the desired inventory.
+// Deletes come first, so a printer whose key changed is deleted and then added again.
+type FleetPlan struct {
+	Actions []FleetAction `json:"actions"`
+}
+
+// Empty reports whether the plan has nothing to do.
+func (p *FleetPlan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// WriteDiff writes one line per action to w.
+func (p *FleetPlan) WriteDiff(w io.Writer) error {
+	if p.Empty() {
+		_, err := fmt.Fprintln(w, "no changes")
+		return err
+	}
+	for _, action := range p.Actions {
+		if _, err := fmt.Fprintln(w, action.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlanFleet computes the actions that turn state into desired.
+func PlanFleet(desired *FleetInventory, state *FleetState) *FleetPlan {
+	var (
+		plan    = &FleetPlan{}
+		wanted  = make(map[string]FleetPrinter, len(desired.Printers))
+		applied = make([]string, 0, len(state.Printers))
+		adds    []FleetAction
+		edits   []FleetAction
+	)
+	for _, p := range desired.Printers {
+		wanted[p.SN] = p
+	}
+	for sn := range state.Printers {
+		applied = append(applied, sn)
+	}
+	sort.Strings(applied)
+	for _, sn := range applied {
+		prev := state.Printers[sn]
+		if p, ok := wanted[sn]; !ok || p.Key != prev.Key {
+			plan.Actions = append(plan.Actions, FleetAction{Type: FleetDelete, Printer: prev})
+		}
+	}
+	for _, p := range desired.Printers {
+		prev, ok := state.Printers[p.SN]
+		switch {
+		case !ok || prev.Key != p.Key:
+			adds = append(adds, FleetAction{Type: FleetAdd, Printer: p})
+		case prev.Name != p.Name || prev.PhoneNum != p.PhoneNum:
+			prev := prev
+			edits = append(edits, FleetAction{Type: FleetEdit, Printer: p, Previous: &prev})
+		}
+	}
+	plan.Actions = append(plan.Actions, adds...)
+	plan.Actions = append(plan.Actions, edits...)
+	return plan
+}
+
+// FleetActionResult is the outcome of one applied FleetAction.
+type FleetActionResult struct {
+	FleetAction
+	OK     bool   `json:"ok"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// FleetReport is the outcome of FleetSync.Sync.
+type FleetReport struct {
+	Plan    *FleetPlan           `json:"plan"`
+	DryRun  bool                 `json:"dryRun"`
+	Results []*FleetActionResult `json:"results,omitempty"`
+}
+
+// FleetSyncOption is the option of FleetSync.
+type FleetSyncOption func(f *FleetSync)
+
+// WithFleetDryRun only prints the diff, nothing is applied nor recorded.
+func WithFleetDryRun(dryRun bool) FleetSyncOption {
+	return func(f *FleetSync) {
+		f.dryRun = dryRun
+	}
+}
+
+// WithFleetOutput sets the writer the diff is printed to, default os.Stdout.
+func WithFleetOutput(w io.Writer) FleetSyncOption {
+	return func(f *FleetSync) {
+		f.out = w
+	}
+}
+
+// FleetSync reconciles the printers of the account with a desired inventory.
+// What it applied is recorded in a local state file, so running it again with the
+// same inventory is a no-op.
+type FleetSync struct {
+	client    *Client
+	statePath string
+	dryRun    bool
+	out       io.Writer
+}
+
+// NewFleetSync returns a new FleetSync recording the applied state to statePath.
+func NewFleetSync(c *Client, statePath string, opts ...FleetSyncOption) *FleetSync {
+	f := &FleetSync{client: c, statePath: statePath, out: os.Stdout}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Sync plans the changes from the state file to desired, prints the diff and applies it
+// unless dry run is set. Successful actions are recorded even when others fail, the
+// failures are reported in the returned *BatchError.
+func (f *FleetSync) Sync(ctx context.Context, desired *FleetInventory) (*FleetReport, error) {
+	if err := desired.Validate(); err != nil {
+		return nil, err
+	}
+	state, err := LoadFleetState(f.statePath)
+	if err != nil {
+		return nil, err
+	}
+	report := &FleetReport{Plan: PlanFleet(desired, state), DryRun: f.dryRun}
+	if err = report.Plan.WriteDiff(f.out); err != nil {
+		return report, err
+	}
+	if f.dryRun || report.Plan.Empty() {
+		return report, nil
+	}
+
+	var (
+		deletes, adds, edits []int
+		errs                 []error
+		failedDeletes        = make(map[string]bool)
+	)
+	report.Results = make([]*FleetActionResult, len(report.Plan.Actions))
+	for i, action := range report.Plan.Actions {
+		report.Results[i] = &FleetActionResult{FleetAction: action}
+		switch action.Type {
+		case FleetDelete:
+			deletes = append(deletes, i)
+		case FleetAdd:
+			adds = append(adds, i)
+		case FleetEdit:
+			edits = append(edits, i)
+		}
+	}
+
+	if len(deletes) > 0 {
+		sns := make([]string, len(deletes))
+		for j, i := range deletes {
+			sns[j] = report.Plan.Actions[i].Printer.SN
+		}
+		// chunk errors are already reflected in the per-SN results.
+		results, err := f.client.DeletePrinters(ctx, sns)
+		if results == nil {
+			return report, err
+		}
+		for j, i := range deletes {
+			report.Results[i].OK, report.Results[i].Reason = results[j].OK, results[j].Reason
+			if results[j].OK {
+				delete(state.Printers, sns[j])
+			} else {
+				failedDeletes[sns[j]] = true
+			}
+		}
+	}
+
+	var specs []PrinterSpec
+	for _, i := range adds {
+		p := report.Plan.Actions[i].Printer
+		if failedDeletes[p.SN] {
+			report.Results[i].Reason = "skipped, delete of the previous key failed"
+			continue
+		}
+		specs = append(specs, p.spec())
+	}
+	if len(specs) > 0 {
+		results, err := f.client.AddPrinters(ctx, specs)
+		if results == nil {
+			// the deletes are applied, the state must not lose them.
+			return report, f.saveState(state, err)
+		}
+		bySN := make(map[string]*PrinterResult, len(results))
+		for _, r := range results {
+			bySN[r.SN] = r
+		}
+		for _, i := range adds {
+			p := report.Plan.Actions[i].Printer
+			if r, ok := bySN[p.SN]; ok {
+				report.Results[i].OK, report.Results[i].Reason = r.OK, r.Reason
+				if r.OK {
+					state.Printers[p.SN] = p
+				}
+			}
+		}
+	}
+
+	for _, i := range edits {
+		var (
+			prev    = report.Plan.Actions[i].Previous
+			applied = report.Plan.Actions[i].Printer
+			err     error
+		)
+		if strings.TrimSpace(applied.PhoneNum) == "" && prev.PhoneNum != "" {
+			applied.PhoneNum = prev.PhoneNum
+		}
+		if applied.Name != prev.Name || applied.PhoneNum != prev.PhoneNum {
+			var resp *PrinterEditResp
+			resp, err = f.client.OpenPrinterEdit(ctx, &PrinterEditReq{SN: applied.SN, Name: applied.Name, PhoneNum: applied.PhoneNum})
+			switch {
+			case err != nil:
+			case resp.Ret != 0:
+				err = &APIError{API: printerEdit, Ret: resp.Ret, Msg: resp.Msg}
+			case !resp.Data:
+				err = fmt.Errorf("feie: edit printer %s failed: %s", applied.SN, resp.Msg)
+			}
+			if err != nil {
+				report.Results[i].Reason = err.Error()
+				continue
+			}
+			state.Printers[applied.SN] = applied
+		}
+		if applied != report.Plan.Actions[i].Printer {
+			report.Results[i].Reason = ErrClearPhoneNum.Error()
+			continue
+		}
+		report.Results[i].OK = true
+	}
+
+	if err = f.saveState(state, nil); err != nil {
+		return report, err
+	}
+	for _, r := range report.Results {
+		if !r.OK {
+			errs = append(errs, fmt.Errorf("%s %s: %s", r.Type, r.Printer.SN, r.Reason))
+		}
+	}
+	if len(errs) > 0 {
+		return report, &BatchError{Errors: errs}
+	}
+	return report, nil
+}
+
+// saveState records the state after the sync applied changes, err is the failure ending the sync.
+func (f *FleetSync) saveState(state *FleetState, err error) error {
+	state.UpdatedAt = time.Now()
+	if serr := state.Save(f.statePath); serr != nil {
+		if err == nil {
+			return serr
+		}
+		return &BatchError{Errors: []error{err, serr}}
+	}
+	return err
+}
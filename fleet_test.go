@@ -0,0 +1,171 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPlanFleet(t *testing.T) {
+	state := &FleetState{Printers: map[string]FleetPrinter{
+		"sn1": {SN: "sn1", Key: "k1", Name: "kitchen"},
+		"sn2": {SN: "sn2", Key: "k2", Name: "bar"},
+		"sn3": {SN: "sn3", Key: "k3", Name: "front"},
+		"sn4": {SN: "sn4", Key: "k4", Name: "old"},
+	}}
+	desired := &FleetInventory{Printers: []FleetPrinter{
+		{SN: "sn1", Key: "k1", Name: "kitchen"},
+		{SN: "sn2", Key: "k2", Name: "bar 2"},
+		{SN: "sn3", Key: "k3-new", Name: "front"},
+		{SN: "sn5", Key: "k5", Name: "new"},
+	}}
+	var got []string
+	for _, action := range PlanFleet(desired, state).Actions {
+		got = append(got, string(action.Type)+" "+action.Printer.SN)
+	}
+	want := []string{"delete sn3", "delete sn4", "add sn3", "add sn5", "edit sn2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PlanFleet() got = %v, want %v", got, want)
+	}
+}
+
+func TestLoadFleetInventory(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "printers.yaml")
+	if err := os.WriteFile(yamlPath, []byte("printers:\n  - sn: sn1\n    key: k1\n    name: kitchen\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := LoadFleetInventory(yamlPath)
+	if err != nil {
+		t.Fatalf("LoadFleetInventory() error = %v", err)
+	}
+	if want := []FleetPrinter{{SN: "sn1", Key: "k1", Name: "kitchen"}}; !reflect.DeepEqual(inv.Printers, want) {
+		t.Errorf("LoadFleetInventory() got = %+v, want %+v", inv.Printers, want)
+	}
+
+	jsonPath := filepath.Join(dir, "printers.json")
+	if err = os.WriteFile(jsonPath, []byte(`{"printers":[{"sn":"sn1","key":"k1"},{"sn":"sn1","key":"k2"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadFleetInventory(jsonPath); err == nil {
+		t.Errorf("LoadFleetInventory() with duplicated sn want error")
+	}
+	if err = os.WriteFile(jsonPath, []byte(`{"printers":[{"sn":"sn1","key":"k1","nmae":"kitchen"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadFleetInventory(jsonPath); err == nil {
+		t.Errorf("LoadFleetInventory() with an unknown key want error")
+	}
+}
+
+func TestFleetSync_Sync(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		apis []string
+	)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		mu.Lock()
+		apis = append(apis, form[APINameField])
+		mu.Unlock()
+		switch form[APINameField] {
+		case printerAddList:
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": map[string][]string{
+				"ok": strings.Split(form[PrinterContentField], "\n"),
+			}}
+		case printerDelList:
+			var ok []string
+			for _, sn := range strings.Split(form[SNListField], "-") {
+				ok = append(ok, sn+"成功")
+			}
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": map[string][]string{"ok": ok}}
+		default:
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": true}
+		}
+	})
+	var (
+		ctx       = context.Background()
+		c         = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		statePath = filepath.Join(t.TempDir(), "fleet.json")
+		desired   = &FleetInventory{Printers: []FleetPrinter{
+			{SN: "sn1", Key: "k1", Name: "kitchen"},
+			{SN: "sn2", Key: "k2", Name: "bar"},
+		}}
+		out bytes.Buffer
+	)
+
+	report, err := NewFleetSync(c, statePath, WithFleetDryRun(true), WithFleetOutput(&out)).Sync(ctx, desired)
+	if err != nil {
+		t.Fatalf("Sync() dry run error = %v", err)
+	}
+	if len(apis) != 0 || len(report.Plan.Actions) != 2 || !strings.Contains(out.String(), "+ add    sn1") {
+		t.Fatalf("Sync() dry run applied %v, diff %q", apis, out.String())
+	}
+	if _, err = os.Stat(statePath); !os.IsNotExist(err) {
+		t.Fatalf("Sync() dry run wrote the state file")
+	}
+
+	fs := NewFleetSync(c, statePath, WithFleetOutput(&out))
+	if _, err = fs.Sync(ctx, desired); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if report, err = fs.Sync(ctx, desired); err != nil || !report.Plan.Empty() {
+		t.Fatalf("Sync() second run error = %v, plan = %+v", err, report.Plan)
+	}
+
+	desired.Printers = []FleetPrinter{{SN: "sn1", Key: "k1", Name: "kitchen 2"}}
+	if _, err = fs.Sync(ctx, desired); err != nil {
+		t.Fatalf("Sync() update error = %v", err)
+	}
+	want := []string{printerAddList, printerDelList, printerEdit}
+	if !reflect.DeepEqual(apis, want) {
+		t.Errorf("Sync() apis = %v, want %v", apis, want)
+	}
+	state, err := LoadFleetState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]FleetPrinter{"sn1": desired.Printers[0]}; !reflect.DeepEqual(state.Printers, want) {
+		t.Errorf("state = %+v, want %+v", state.Printers, want)
+	}
+
+	desired.Printers[0].PhoneNum = "13800000000"
+	if _, err = fs.Sync(ctx, desired); err != nil {
+		t.Fatalf("Sync() set phoneNum error = %v", err)
+	}
+	desired.Printers[0].PhoneNum = ""
+	report, err = fs.Sync(ctx, desired)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || report.Results[0].OK || report.Results[0].Reason != ErrClearPhoneNum.Error() {
+		t.Fatalf("Sync() clear phoneNum error = %v, results = %+v", err, report.Results)
+	}
+	if want := []string{printerAddList, printerDelList, printerEdit, printerEdit}; !reflect.DeepEqual(apis, want) {
+		t.Errorf("Sync() clear phoneNum apis = %v, want %v", apis, want)
+	}
+	if state, err = LoadFleetState(statePath); err != nil || state.Printers["sn1"].PhoneNum != "13800000000" {
+		t.Errorf("state after clearing phoneNum = %+v, %v, want the old number kept", state, err)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+// ErrIdempotencyInDoubt is returned for an idempotency key whose earlier request failed after it
+// may have reached feieyun, such as on a timeout: the ticket may be printed, so it is not sent
+// again. Check the printer, then print with a new key or once the key expired.
+var ErrIdempotencyInDoubt = errors.New("feie: idempotency key in doubt, an earlier request may have printed")
+
+// idempotencyInFlight is the value recorded for a key while its request is sent, no order ID
+// is shaped like it.
+const idempotencyInFlight = "\x00in-flight"
+
+// IdempotencyStore remembers the order ID returned for an idempotency key.
+type IdempotencyStore interface {
+	// Get returns the order ID recorded for key, ok is false when there is none or it expired.
+	Get(ctx context.Context, key string) (orderID string, ok bool, err error)
+	// Set records the order ID for key, the entry expires after ttl. The client also records
+	// an in-flight marker before sending a request, and an empty order ID to clear it.
+	Set(ctx context.Context, key, orderID string, ttl time.Duration) error
+}
+
+// idempotencyEntry is an entry of MemoryIdempotencyStore.
+type idempotencyEntry struct {
+	orderID string
+	expires time.Time
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore kept in memory.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]idempotencyEntry
+	sets    int
+}
+
+// NewMemoryIdempotencyStore returns a new MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]idempotencyEntry)}
+}
+
+// Get returns the order ID recorded for key.
+func (s *MemoryIdempotencyStore) Get(_ context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return "", false, nil
+	}
+	if !time.Now().Before(entry.expires) {
+		delete(s.entries, key)
+		return "", false, nil
+	}
+	return entry.orderID, true, nil
+}
+
+// Set records the order ID for key, expired entries are swept every 1024 calls.
+func (s *MemoryIdempotencyStore) Set(_ context.Context, key, orderID string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.entries[key] = idempotencyEntry{orderID: orderID, expires: now.Add(ttl)}
+	if s.sets++; s.sets%1024 == 0 {
+		for k, entry := range s.entries {
+			if !now.Before(entry.expires) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	return nil
+}
+
+// idempotencyLocks serializes the requests sharing an idempotency key, requests with
+// other keys never wait on each other.
+type idempotencyLocks struct {
+	mu   sync.Mutex
+	keys map[string]*idempotencyLock
+}
+
+// idempotencyLock is the lock of one key, dropped when its last holder unlocks.
+type idempotencyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock locks key and returns its unlock function.
+func (l *idempotencyLocks) lock(key string) func() {
+	l.mu.Lock()
+	if l.keys == nil {
+		l.keys = make(map[string]*idempotencyLock)
+	}
+	k, ok := l.keys[key]
+	if !ok {
+		k = &idempotencyLock{}
+		l.keys[key] = k
+	}
+	k.refs++
+	l.mu.Unlock()
+
+	k.mu.Lock()
+	return func() {
+		k.mu.Unlock()
+		l.mu.Lock()
+		if k.refs--; k.refs == 0 {
+			delete(l.keys, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// idempotencyKey returns the store key of a caller-supplied key, scoped by the signing user, API
+// and printer: the same key sent to another printer is another ticket.
+func (c *Client) idempotencyKey(api, user, sn, key string) string {
+	if key = strings.TrimSpace(key); key == "" || c.op.IdempotencyStore == nil {
+		return ""
+	}
+	return user + "|" + api + "|" + sn + "|" + key
+}
+
+// beginIdempotent returns the order ID recorded for the store key, or marks the key in flight
+// when there is none. The key lock must be held until endIdempotent.
+func (c *Client) beginIdempotent(ctx context.Context, key string) (string, error) {
+	orderID, ok, err := c.op.IdempotencyStore.Get(ctx, key)
+	if err != nil {
+		return "", &notSentError{err: err}
+	}
+	switch {
+	case ok && orderID == idempotencyInFlight:
+		return "", ErrIdempotencyInDoubt
+	case ok && orderID != "":
+		c.logEvent(ctx, hlog.LevelInfo, "feie idempotency key already printed",
+			Field{Key: "idempotency_key", Value: key}, Field{Key: "order_id", Value: orderID})
+		return orderID, nil
+	}
+	if err = c.op.IdempotencyStore.Set(ctx, key, idempotencyInFlight, c.op.IdempotencyTTL); err != nil {
+		return "", &notSentError{err: err}
+	}
+	return "", nil
+}
+
+// endIdempotent records the outcome of the request sent for the store key. The in-flight marker
+// is kept when err leaves the ticket in doubt, and cleared when no order was printed. A failure
+// is only logged, the ticket is printed and returning an error would invite a retry printing it again.
+func (c *Client) endIdempotent(ctx context.Context, key, orderID string, err error) {
+	if err != nil && !requestNotSent(err) {
+		c.logEvent(ctx, hlog.LevelWarn, "feie idempotency key in doubt",
+			Field{Key: "idempotency_key", Value: key}, Field{Key: "error", Value: err})
+		return
+	}
+	if err = c.op.IdempotencyStore.Set(ctx, key, orderID, c.op.IdempotencyTTL); err != nil {
+		c.logEvent(ctx, hlog.LevelError, "feie remember idempotency key failed",
+			Field{Key: "idempotency_key", Value: key}, Field{Key: "order_id", Value: orderID}, Field{Key: "error", Value: err})
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_OpenPrintMsg_IdempotencyKey(t *testing.T) {
+	var prints int32
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		n := atomic.AddInt32(&prints, 1)
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_20160919184316_" + strconv.Itoa(int(n))}
+	})
+	var (
+		ctx = context.Background()
+		c   = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL), WithIdempotencyTTL(200*time.Millisecond))
+		wg  sync.WaitGroup
+		ids = make([]string, 5)
+	)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := c.OpenPrintMsg(ctx, &PrintMsgReq{SN: "sn1", Content: "ticket", IdempotencyKey: "order-1"})
+			if err != nil {
+				t.Errorf("OpenPrintMsg() error = %v", err)
+				return
+			}
+			ids[i] = resp.Data
+		}(i)
+	}
+	wg.Wait()
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Errorf("OpenPrintMsg() order IDs = %v, want the same", ids)
+			break
+		}
+	}
+	if prints != 1 {
+		t.Errorf("prints = %d, want 1", prints)
+	}
+
+	if _, err := c.OpenPrintLabelMsg(ctx, &PrintLabelMsgReq{SN: "sn1", Content: "label", IdempotencyKey: "order-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.OpenPrintMsg(ctx, &PrintMsgReq{SN: "sn1", Content: "ticket"}); err != nil {
+		t.Fatal(err)
+	}
+	if prints != 3 {
+		t.Errorf("prints = %d, want 3 after a label and a print without key", prints)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	resp, err := c.OpenPrintMsg(ctx, &PrintMsgReq{SN: "sn1", Content: "ticket", IdempotencyKey: "order-1"})
+	if err != nil || resp.Data == ids[0] || prints != 4 {
+		t.Errorf("OpenPrintMsg() after TTL = %+v, %v, prints = %d, want a new order", resp, err, prints)
+	}
+}
+
+func TestClient_IdempotencyKey_CredentialsProvider(t *testing.T) {
+	var prints int32
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		n := atomic.AddInt32(&prints, 1)
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[UserField] + "_" + strconv.Itoa(int(n))}
+	})
+	var (
+		ctx   = context.Background()
+		store = NewMemoryIdempotencyStore()
+		user  atomic.Value
+	)
+	user.Store("a")
+	provider := CredentialsProviderFunc(func(ctx context.Context) (Credentials, error) {
+		return Credentials{User: user.Load().(string), UKey: "ukey"}, nil
+	})
+	c := New(ctx, WithUser("static"), WithUserKey("ukey"), WithGateway(srv.URL),
+		WithCredentialsProvider(provider), WithIdempotencyStore(store))
+	first, err := c.OpenPrintMsg(ctx, &PrintMsgReq{SN: "sn1", Content: "ticket", IdempotencyKey: "order-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok, _ := store.Get(ctx, "a|"+printMsg+"|sn1|order-1"); !ok || id != first.Data {
+		t.Errorf("store key of provider user a = %q, %v, want %q", id, ok, first.Data)
+	}
+	user.Store("b")
+	second, err := c.OpenPrintMsg(ctx, &PrintMsgReq{SN: "sn1", Content: "ticket", IdempotencyKey: "order-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Data == first.Data || prints != 2 {
+		t.Errorf("order of user b = %q, prints = %d, want a new order signed by b", second.Data, prints)
+	}
+}
+
+func TestIdempotencyLocks(t *testing.T) {
+	var l idempotencyLocks
+	unlock := l.lock("a")
+	done := make(chan struct{})
+	go func() {
+		l.lock("b")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("key b waited for key a")
+	}
+	waited := make(chan struct{})
+	go func() {
+		l.lock("a")()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("key a was locked twice")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-waited
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.keys) != 0 {
+		t.Errorf("keys = %d after unlock, want 0", len(l.keys))
+	}
+}
+
+func TestClient_IdempotencyKey_InDoubt(t *testing.T) {
+	var prints int32
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		n := atomic.AddInt32(&prints, 1)
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_20160919184316_" + strconv.Itoa(int(n))}
+	})
+	var (
+		ctx  = context.Background()
+		next = NewHTTPTransport(0)
+		drop = map[string]error{
+			"dropped":  errors.New("read: connection reset by peer"),
+			"not sent": &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+		}
+		fail error
+	)
+	transport := TransportFunc(func(ctx context.Context, gateway string, form map[string]string) ([]byte, error) {
+		if fail != nil {
+			if _, ok := fail.(*net.OpError); ok {
+				return nil, fail
+			}
+			// the gateway accepted the print, the response is lost on the way back
+			if _, err := next.Do(ctx, gateway, form); err != nil {
+				return nil, err
+			}
+			return nil, fail
+		}
+		return next.Do(ctx, gateway, form)
+	})
+	c := New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL), WithTransport(transport))
+	tests := []struct {
+		name       string
+		sn         string
+		wantPrints int32
+		wantErr    error
+	}{
+		{"dropped", "sn1", 1, ErrIdempotencyInDoubt},
+		{"not sent", "sn2", 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&prints, 0)
+			fail = drop[tt.name]
+			req := &PrintMsgReq{SN: tt.sn, Content: "ticket", IdempotencyKey: "order-1"}
+			if _, err := c.OpenPrintMsg(ctx, req); err == nil {
+				t.Fatal("OpenPrintMsg() succeeded, want the transport error")
+			}
+			fail = nil
+			resp, err := c.OpenPrintMsg(ctx, req)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && resp.Data == "") {
+				t.Errorf("OpenPrintMsg() retry = %+v, %v, want %v", resp, err, tt.wantErr)
+			}
+			if n := atomic.LoadInt32(&prints); n != tt.wantPrints {
+				t.Errorf("prints = %d, want %d", n, tt.wantPrints)
+			}
+		})
+	}
+
+	// the same key on another printer is another ticket
+	resp, err := c.OpenPrintMsg(ctx, &PrintMsgReq{SN: "sn3", Content: "ticket", IdempotencyKey: "order-1"})
+	if err != nil || resp.Data != "sn3_20160919184316_2" {
+		t.Errorf("OpenPrintMsg() on sn3 = %+v, %v, want a new order", resp, err)
+	}
+}
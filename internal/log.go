@@ -19,9 +19,6 @@
 package internal
 
 import (
-	"context"
-	"os"
-
 	"github.com/cloudwego/hertz/pkg/common/hlog"
 	hertzzap "github.com/hertz-contrib/logger/zap"
 	"github.com/natefinch/lumberjack"
@@ -29,60 +26,34 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-// InitLog init log
-func InitLog(ctx context.Context, path string, level hlog.Level) hlog.FullLogger {
-	dynamicLevel := zap.NewAtomicLevel()
-	dynamicLevel.SetLevel(zap.DebugLevel)
+// FileConfig is the log file and its rotation.
+type FileConfig struct {
+	Filename   string // 日志文件
+	MaxSize    int    // 单个文件最大 MB
+	MaxBackups int    // 保留的旧文件数
+	MaxAge     int    // 旧文件保留天数
+	Compress   bool   // 是否压缩旧文件
+	LocalTime  bool   // 旧文件名是否使用本地时间
+}
+
+// NewFileLogger returns a logger writing JSON lines to the rotated file.
+// It leaves the global hlog logger untouched.
+func NewFileLogger(cfg FileConfig, level hlog.Level) hlog.FullLogger {
 	logger := hertzzap.NewLogger(
-		hertzzap.WithCores([]hertzzap.CoreConfig{
-			{
-				Enc: zapcore.NewConsoleEncoder(humanEncoderConfig()),
-				Ws:  os.Stdout,
-				Lvl: dynamicLevel,
-			},
-			{
-				Enc: zapcore.NewJSONEncoder(humanEncoderConfig()),
-				Ws:  getWriteSyncer(path + "/all.log"),
-				Lvl: zap.NewAtomicLevelAt(zapcore.DebugLevel),
-			},
-			{
-				Enc: zapcore.NewJSONEncoder(humanEncoderConfig()),
-				Ws:  getWriteSyncer(path + "/debug.log"),
-				Lvl: zap.NewAtomicLevelAt(zapcore.LevelOf(
-					zap.LevelEnablerFunc(func(lev zapcore.Level) bool {
-						return lev == zap.DebugLevel
-					}))),
-			},
-			{
-				Enc: zapcore.NewJSONEncoder(humanEncoderConfig()),
-				Ws:  getWriteSyncer(path + "/info.log"),
-				Lvl: zap.NewAtomicLevelAt(zapcore.LevelOf(
-					zap.LevelEnablerFunc(func(lev zapcore.Level) bool {
-						return lev == zap.InfoLevel
-					}))),
-			},
-			{
-				Enc: zapcore.NewJSONEncoder(humanEncoderConfig()),
-				Ws:  getWriteSyncer(path + "/warn.log"),
-				Lvl: zap.NewAtomicLevelAt(zapcore.LevelOf(
-					zap.LevelEnablerFunc(func(lev zapcore.Level) bool {
-						return lev == zap.WarnLevel
-					}))),
-			},
-			{
-				Enc: zapcore.NewJSONEncoder(humanEncoderConfig()),
-				Ws:  getWriteSyncer(path + "/error.log"),
-				Lvl: zap.NewAtomicLevelAt(zapcore.LevelOf(
-					zap.LevelEnablerFunc(func(lev zapcore.Level) bool {
-						return lev >= zap.ErrorLevel
-					}))),
-			},
-		}...),
+		hertzzap.WithCores(hertzzap.CoreConfig{
+			Enc: zapcore.NewJSONEncoder(humanEncoderConfig()),
+			Ws: zapcore.AddSync(&lumberjack.Logger{
+				Filename:   cfg.Filename,
+				MaxSize:    cfg.MaxSize,
+				MaxBackups: cfg.MaxBackups,
+				MaxAge:     cfg.MaxAge,
+				Compress:   cfg.Compress,
+				LocalTime:  cfg.LocalTime,
+			}),
+			Lvl: zap.NewAtomicLevelAt(zapcore.DebugLevel),
+		}),
 	)
-	defer logger.Sync()
-	hlog.SetLogger(logger)
-	hlog.SetLevel(level)
-	logger.CtxInfof(ctx, "feie dada init log start level:%s", level)
+	logger.SetLevel(level)
 	return logger
 }
 
@@ -95,18 +66,6 @@ func humanEncoderConfig() zapcore.EncoderConfig {
 	return cfg
 }
 
-func getWriteSyncer(file string) zapcore.WriteSyncer {
-	lumberJackLogger := &lumberjack.Logger{
-		Filename:   file,
-		MaxSize:    10,
-		MaxBackups: 50000,
-		MaxAge:     1000,
-		Compress:   true,
-		LocalTime:  true,
-	}
-	return zapcore.AddSync(lumberJackLogger)
-}
-
 // encoderConfig encoder config for testing, copy from zap
 func encoderConfig() zapcore.EncoderConfig {
 	return zapcore.EncoderConfig{
@@ -0,0 +1,55 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package internal
+
+import (
+	"context"
+	"io"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+// NopLogger is a hlog.FullLogger discarding every log, Fatal does not exit.
+type NopLogger struct{}
+
+var _ hlog.FullLogger = NopLogger{}
+
+func (NopLogger) Trace(...interface{})                               {}
+func (NopLogger) Debug(...interface{})                               {}
+func (NopLogger) Info(...interface{})                                {}
+func (NopLogger) Notice(...interface{})                              {}
+func (NopLogger) Warn(...interface{})                                {}
+func (NopLogger) Error(...interface{})                               {}
+func (NopLogger) Fatal(...interface{})                               {}
+func (NopLogger) Tracef(string, ...interface{})                      {}
+func (NopLogger) Debugf(string, ...interface{})                      {}
+func (NopLogger) Infof(string, ...interface{})                       {}
+func (NopLogger) Noticef(string, ...interface{})                     {}
+func (NopLogger) Warnf(string, ...interface{})                       {}
+func (NopLogger) Errorf(string, ...interface{})                      {}
+func (NopLogger) Fatalf(string, ...interface{})                      {}
+func (NopLogger) CtxTracef(context.Context, string, ...interface{})  {}
+func (NopLogger) CtxDebugf(context.Context, string, ...interface{})  {}
+func (NopLogger) CtxInfof(context.Context, string, ...interface{})   {}
+func (NopLogger) CtxNoticef(context.Context, string, ...interface{}) {}
+func (NopLogger) CtxWarnf(context.Context, string, ...interface{})   {}
+func (NopLogger) CtxErrorf(context.Context, string, ...interface{})  {}
+func (NopLogger) CtxFatalf(context.Context, string, ...interface{})  {}
+func (NopLogger) SetLevel(hlog.Level)                                {}
+func (NopLogger) SetOutput(io.Writer)                                {}
@@ -0,0 +1,156 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+
+	"github.com/houseme/feie/internal"
+)
+
+// recordLogger records the debug and warn logs and the level changes.
+type recordLogger struct {
+	internal.NopLogger
+	mu     sync.Mutex
+	logs   []string
+	levels []hlog.Level
+}
+
+func (l *recordLogger) CtxDebugf(_ context.Context, format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, fmt.Sprintf(format, v...))
+}
+
+func (l *recordLogger) CtxWarnf(_ context.Context, format string, v ...interface{}) {
+	l.CtxDebugf(context.Background(), format, v...)
+}
+
+func (l *recordLogger) SetLevel(level hlog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.levels = append(l.levels, level)
+}
+
+func queryStatus(t *testing.T, opts ...Option) {
+	t.Helper()
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": "在线，工作状态正常。"}
+	})
+	opts = append([]Option{WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL)}, opts...)
+	c := New(context.Background(), opts...)
+	if _, err := c.OpenQueryPrinterStatus(context.Background(), &QueryPrinterStatusReq{SN: "sn"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew_NoGlobalSideEffects(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	global := hlog.DefaultLogger()
+
+	queryStatus(t, WithLevel(Level(hlog.LevelTrace)))
+
+	if hlog.DefaultLogger() != global {
+		t.Error("New replaced the global hlog logger")
+	}
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Errorf("New wrote %d files to TMPDIR, want none", len(entries))
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	logger := &recordLogger{}
+	queryStatus(t, WithLogger(logger), WithLevel(Level(hlog.LevelError)))
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.logs) == 0 || !strings.Contains(logger.logs[len(logger.logs)-1], "api=Open_queryPrinterStatus sn=sn ret=0 latency=") {
+		t.Errorf("caller logger logs = %q, want the request with its fields", logger.logs)
+	}
+	if len(logger.levels) > 0 {
+		t.Errorf("SetLevel called on caller logger with %v", logger.levels)
+	}
+}
+
+func TestWithFileLog(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		opts  []Option
+		file  string
+		empty bool
+	}{
+		{"file", []Option{WithFileLog(LogFileConfig{Filename: filepath.Join(dir, "a", "sdk.log")}), WithLevel(Level(hlog.LevelDebug))}, filepath.Join(dir, "a", "sdk.log"), false},
+		{"level", []Option{WithFileLog(LogFileConfig{Filename: filepath.Join(dir, "b", "sdk.log")}), WithLevel(Level(hlog.LevelError))}, filepath.Join(dir, "b", "sdk.log"), true},
+		{"log path", []Option{WithLogPath(filepath.Join(dir, "c")), WithLevel(Level(hlog.LevelDebug))}, filepath.Join(dir, "c", "feie.log"), false},
+		{"precedence", []Option{WithLogger(&recordLogger{}), WithFileLog(LogFileConfig{Filename: filepath.Join(dir, "d", "sdk.log")}), WithLevel(Level(hlog.LevelDebug))}, filepath.Join(dir, "d", "sdk.log"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queryStatus(t, tt.opts...)
+			content, err := os.ReadFile(tt.file)
+			if err != nil && !(tt.empty && os.IsNotExist(err)) {
+				t.Fatal(err)
+			}
+			if empty := len(content) == 0; empty != tt.empty {
+				t.Errorf("log file empty = %v, want %v: %s", empty, tt.empty, content)
+			}
+		})
+	}
+}
+
+func TestClient_SetLogger_Concurrent(t *testing.T) {
+	var (
+		ctx = context.Background()
+		c   = New(ctx)
+		wg  sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.logEvent(ctx, hlog.LevelWarn, "feie test", Field{Key: "sn", Value: "sn"})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.SetLogger(&recordLogger{})
+			}
+		}()
+	}
+	wg.Wait()
+	c.SetLogger(nil)
+	if !c.logDisabled() {
+		t.Error("SetLogger(nil) did not discard the logs")
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+
+	"github.com/houseme/feie/internal"
+)
+
+// Field is a key/value pair of a structured log event.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// StructuredLogger is a Logger taking key/value fields, the loggers of WithZapLogger and
+// WithSlogLogger implement it. The SDK events, such as a request with its api, sn, order_id,
+// ret and latency, are logged as fields to a StructuredLogger and as "msg key=value" text
+// to any other Logger.
+type StructuredLogger interface {
+	Logger
+	CtxLogFields(ctx context.Context, level Level, msg string, fields ...Field)
+}
+
+// loggerHolder wraps the logger of a Client, atomic.Value needs the same concrete type on every Store.
+type loggerHolder struct {
+	Logger
+}
+
+// log returns the logger of the client.
+func (c *Client) log() Logger {
+	return c.logger.Load().(loggerHolder).Logger
+}
+
+// logEvent logs the SDK event msg with fields.
+func (c *Client) logEvent(ctx context.Context, level hlog.Level, msg string, fields ...Field) {
+	logger := c.log()
+	if sl, ok := logger.(StructuredLogger); ok {
+		sl.CtxLogFields(ctx, Level(level), msg, fields...)
+		return
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+	}
+	switch level {
+	case hlog.LevelTrace:
+		logger.CtxTracef(ctx, "%s", b.String())
+	case hlog.LevelDebug:
+		logger.CtxDebugf(ctx, "%s", b.String())
+	case hlog.LevelInfo:
+		logger.CtxInfof(ctx, "%s", b.String())
+	case hlog.LevelNotice:
+		logger.CtxNoticef(ctx, "%s", b.String())
+	case hlog.LevelWarn:
+		logger.CtxWarnf(ctx, "%s", b.String())
+	default:
+		logger.CtxErrorf(ctx, "%s", b.String())
+	}
+}
+
+// logDisabled reports whether the client discards every log.
+func (c *Client) logDisabled() bool {
+	_, ok := c.log().(internal.NopLogger)
+	return ok
+}
@@ -0,0 +1,263 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+// ErrMonitorRun is returned by Monitor.Run when the monitor already runs or ran: Events is closed
+// when the first Run returns, a monitor runs once.
+var ErrMonitorRun = errors.New("feie: monitor already running or stopped")
+
+// StatusChanged is emitted by Monitor when the status of a printer changes.
+// The first observation of a printer is emitted with Old set to PrinterStatusUnknown.
+type StatusChanged struct {
+	SN  string        `json:"sn"`
+	Old PrinterStatus `json:"old"`
+	New PrinterStatus `json:"new"`
+	At  time.Time     `json:"at"`
+}
+
+// MonitorOption is the option of Monitor.
+type MonitorOption func(m *Monitor)
+
+// WithMonitorInterval sets the interval between two polling rounds, default 1 minute.
+func WithMonitorInterval(d time.Duration) MonitorOption {
+	return func(m *Monitor) {
+		if d > 0 {
+			m.interval = d
+		}
+	}
+}
+
+// WithMonitorJitter adds a random delay in [0, d) to every interval, default 10 seconds.
+func WithMonitorJitter(d time.Duration) MonitorOption {
+	return func(m *Monitor) {
+		if d >= 0 {
+			m.jitter = d
+		}
+	}
+}
+
+// WithMonitorConcurrency sets the number of printers queried concurrently, default 4.
+func WithMonitorConcurrency(n int) MonitorOption {
+	return func(m *Monitor) {
+		if n > 0 {
+			m.concurrency = n
+		}
+	}
+}
+
+// WithMonitorClock sets the clock, default the system clock.
+func WithMonitorClock(clock Clock) MonitorOption {
+	return func(m *Monitor) {
+		m.clock = clock
+	}
+}
+
+// WithMonitorCallback calls fn for every StatusChanged instead of sending it to Events.
+func WithMonitorCallback(fn func(ctx context.Context, event StatusChanged)) MonitorOption {
+	return func(m *Monitor) {
+		m.callback = fn
+	}
+}
+
+// Monitor polls Open_queryPrinterStatus for a set of printers and emits StatusChanged events.
+type Monitor struct {
+	client      *Client
+	interval    time.Duration
+	jitter      time.Duration
+	concurrency int
+	clock       Clock
+	callback    func(ctx context.Context, event StatusChanged)
+	events      chan StatusChanged
+
+	mu     sync.RWMutex
+	status map[string]PrinterStatus
+	rand   *rand.Rand
+	ran    bool
+}
+
+// NewMonitor returns a new Monitor of the printers sns.
+func NewMonitor(c *Client, sns []string, opts ...MonitorOption) *Monitor {
+	m := &Monitor{
+		client:      c,
+		interval:    time.Minute,
+		jitter:      10 * time.Second,
+		concurrency: 4,
+		clock:       systemClock{},
+		events:      make(chan StatusChanged, 64),
+		status:      make(map[string]PrinterStatus, len(sns)),
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	for _, sn := range sns {
+		m.status[sn] = PrinterStatusUnknown
+	}
+	return m
+}
+
+// Events returns the channel StatusChanged events are sent to, it is closed when Run returns.
+// The channel must be drained unless WithMonitorCallback is set, otherwise polling blocks.
+func (m *Monitor) Events() <-chan StatusChanged {
+	return m.events
+}
+
+// Add starts monitoring the printer sn from the next round.
+func (m *Monitor) Add(sn string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.status[sn]; !ok {
+		m.status[sn] = PrinterStatusUnknown
+	}
+}
+
+// Remove stops monitoring the printer sn.
+func (m *Monitor) Remove(sn string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.status, sn)
+}
+
+// Status returns the last known status of the printer sn.
+func (m *Monitor) Status(sn string) PrinterStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status[sn]
+}
+
+// Run polls until ctx is done and returns ctx.Err(), the first round starts immediately.
+// A monitor runs once, a second call returns ErrMonitorRun.
+func (m *Monitor) Run(ctx context.Context) error {
+	m.mu.Lock()
+	if m.ran {
+		m.mu.Unlock()
+		return ErrMonitorRun
+	}
+	m.ran = true
+	m.mu.Unlock()
+	defer close(m.events)
+	for {
+		if err := m.poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.clock.After(m.next()):
+		}
+	}
+}
+
+// next returns the interval before the next round.
+func (m *Monitor) next() time.Duration {
+	if m.jitter <= 0 {
+		return m.interval
+	}
+	return m.interval + time.Duration(m.rand.Int63n(int64(m.jitter)))
+}
+
+// poll queries every printer once and emits the changes in SN order.
+func (m *Monitor) poll(ctx context.Context) error {
+	m.mu.RLock()
+	sns := make([]string, 0, len(m.status))
+	for sn := range m.status {
+		sns = append(sns, sn)
+	}
+	m.mu.RUnlock()
+	sort.Strings(sns)
+
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, m.concurrency)
+		results = make([]PrinterStatus, len(sns))
+	)
+	for i, sn := range sns {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int, sn string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			status, err := m.client.printerStatus(ctx, sn)
+			if err != nil {
+				m.client.logEvent(ctx, hlog.LevelWarn, "feie monitor query printer status failed",
+					Field{Key: "sn", Value: sn}, Field{Key: "error", Value: err})
+			}
+			results[i] = status
+		}(i, sn)
+	}
+	wg.Wait()
+
+	var (
+		now    = m.clock.Now()
+		events []StatusChanged
+	)
+	m.mu.Lock()
+	for i, sn := range sns {
+		old, ok := m.status[sn]
+		if !ok || results[i] == PrinterStatusUnknown || old == results[i] {
+			continue
+		}
+		m.status[sn] = results[i]
+		events = append(events, StatusChanged{SN: sn, Old: old, New: results[i], At: now})
+	}
+	m.mu.Unlock()
+
+	for _, event := range events {
+		if m.callback != nil {
+			m.callback(ctx, event)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case m.events <- event:
+		}
+	}
+	return nil
+}
+
+// printerStatus queries the status of the printer sn, a non-zero ret is returned as *APIError.
+func (c *Client) printerStatus(ctx context.Context, sn string) (PrinterStatus, error) {
+	resp, err := c.OpenQueryPrinterStatus(ctx, &QueryPrinterStatusReq{SN: sn})
+	if err != nil {
+		return PrinterStatusUnknown, err
+	}
+	if resp.Ret != 0 {
+		return PrinterStatusUnknown, &APIError{API: queryPrinterStatus, Ret: resp.Ret, Msg: resp.Msg}
+	}
+	return resp.Status(), nil
+}
@@ -0,0 +1,158 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, fakeWaiter{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock and fires the waiters that are due.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	waiters := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.at.After(f.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = waiters
+}
+
+// BlockUntil waits until n goroutines wait on the clock.
+func (f *fakeClock) BlockUntil(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		count := len(f.waiters)
+		f.mu.Unlock()
+		if count >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d clock waiters", n)
+}
+
+func TestMonitor_Run(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		status = map[string]string{"sn1": "在线，工作状态正常。", "sn2": "离线。"}
+	)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		data, ok := status[form[SNField]]
+		if !ok {
+			return map[string]interface{}{"ret": 1002, "msg": "打印机编号错误"}
+		}
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": data}
+	})
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		clock       = newFakeClock()
+		c           = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		m           = NewMonitor(c, []string{"sn1", "sn2", "sn3"}, WithMonitorClock(clock), WithMonitorInterval(time.Minute), WithMonitorJitter(0))
+		done        = make(chan error, 1)
+	)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+	clock.BlockUntil(t, 1)
+	if err := m.Run(ctx); err != ErrMonitorRun {
+		t.Errorf("Run() while running error = %v, want %v", err, ErrMonitorRun)
+	}
+
+	expect := func(want StatusChanged) {
+		t.Helper()
+		select {
+		case got := <-m.Events():
+			if got.SN != want.SN || got.Old != want.Old || got.New != want.New {
+				t.Errorf("event = %+v, want %+v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %+v", want)
+		}
+	}
+	expect(StatusChanged{SN: "sn1", Old: PrinterStatusUnknown, New: PrinterStatusOnline})
+	expect(StatusChanged{SN: "sn2", Old: PrinterStatusUnknown, New: PrinterStatusOffline})
+
+	clock.BlockUntil(t, 1)
+	mu.Lock()
+	status["sn1"] = "在线，工作状态不正常。"
+	mu.Unlock()
+	clock.Advance(time.Minute)
+	expect(StatusChanged{SN: "sn1", Old: PrinterStatusOnline, New: PrinterStatusAbnormal})
+	if got := m.Status("sn1"); got != PrinterStatusAbnormal {
+		t.Errorf("Status() = %v, want %v", got, PrinterStatusAbnormal)
+	}
+
+	clock.BlockUntil(t, 1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+	if _, ok := <-m.Events(); ok {
+		t.Errorf("Events() not closed after Run returned")
+	}
+	if err := m.Run(context.Background()); err != ErrMonitorRun {
+		t.Errorf("Run() after it returned error = %v, want %v", err, ErrMonitorRun)
+	}
+}
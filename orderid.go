@@ -0,0 +1,132 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// orderIDTimeLayout is the layout of the creation time part of an order ID.
+const orderIDTimeLayout = "20060102150405"
+
+// OrderID is the order ID returned by Open_printMsg and Open_printLabelMsg, shaped as
+// sn_yyyyMMddHHmmss_sequence, such as 816501678_20160919184316_1419533539.
+type OrderID string
+
+// ParseOrderID parses and validates an order ID.
+func ParseOrderID(s string) (OrderID, error) {
+	id := OrderID(strings.TrimSpace(s))
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// parts splits the order ID into SN, creation time and sequence, the SN may contain '_'.
+func (id OrderID) parts() (sn, created, seq string, ok bool) {
+	s := string(id)
+	last := strings.LastIndexByte(s, '_')
+	if last < 0 {
+		return "", "", "", false
+	}
+	middle := strings.LastIndexByte(s[:last], '_')
+	if middle < 0 {
+		return "", "", "", false
+	}
+	return s[:middle], s[middle+1 : last], s[last+1:], true
+}
+
+// Validate checks the order ID is shaped as sn_yyyyMMddHHmmss_sequence.
+func (id OrderID) Validate() error {
+	sn, created, seq, ok := id.parts()
+	if !ok || sn == "" || seq == "" {
+		return fmt.Errorf("feie: invalid order id %q", string(id))
+	}
+	if _, err := time.ParseInLocation(orderIDTimeLayout, created, Shanghai); err != nil {
+		return fmt.Errorf("feie: invalid order id %q time: %w", string(id), err)
+	}
+	if _, err := strconv.ParseUint(seq, 10, 64); err != nil {
+		return fmt.Errorf("feie: invalid order id %q sequence", string(id))
+	}
+	return nil
+}
+
+// SN returns the printer the order was sent to, empty when the order ID is invalid.
+func (id OrderID) SN() string {
+	if id.Validate() != nil {
+		return ""
+	}
+	sn, _, _, _ := id.parts()
+	return sn
+}
+
+// Time returns the creation time of the order in Asia/Shanghai, zero when the order ID is invalid.
+func (id OrderID) Time() time.Time {
+	_, created, _, ok := id.parts()
+	if !ok {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation(orderIDTimeLayout, created, Shanghai)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Seq returns the sequence part of the order ID, empty when the order ID is invalid.
+func (id OrderID) Seq() string {
+	if id.Validate() != nil {
+		return ""
+	}
+	_, _, seq, _ := id.parts()
+	return seq
+}
+
+// String returns the order ID.
+func (id OrderID) String() string {
+	return string(id)
+}
+
+// MarshalJSON marshals the order ID as a JSON string.
+func (id OrderID) MarshalJSON() ([]byte, error) {
+	return sonic.Marshal(string(id))
+}
+
+// UnmarshalJSON unmarshals and validates a JSON string, an empty string is the zero order ID.
+func (id *OrderID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := sonic.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*id = ""
+		return nil
+	}
+	parsed, err := ParseOrderID(s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseOrderID(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		sn      string
+		seq     string
+		wantErr bool
+	}{
+		{name: "valid", in: "816501678_20160919184316_1419533539", sn: "816501678", seq: "1419533539"},
+		{name: "sn with separator", in: "a_b_20160919184316_1", sn: "a_b", seq: "1"},
+		{name: "empty", in: "", wantErr: true},
+		{name: "missing sequence", in: "816501678_20160919184316", wantErr: true},
+		{name: "bad time", in: "816501678_20161319184316_1419533539", wantErr: true},
+		{name: "bad sequence", in: "816501678_20160919184316_x", wantErr: true},
+		{name: "empty sn", in: "_20160919184316_1419533539", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := ParseOrderID(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOrderID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if id.SN() != tt.sn || id.Seq() != tt.seq {
+				t.Errorf("ParseOrderID() got sn = %q seq = %q, want %q %q", id.SN(), id.Seq(), tt.sn, tt.seq)
+			}
+		})
+	}
+}
+
+func TestOrderID_Time(t *testing.T) {
+	id := OrderID("816501678_20160919184316_1419533539")
+	want := time.Date(2016, 9, 19, 10, 43, 16, 0, time.UTC)
+	if got := id.Time(); !got.Equal(want) {
+		t.Errorf("Time() = %v, want %v", got, want)
+	}
+	if got := OrderID("bad").Time(); !got.IsZero() {
+		t.Errorf("Time() on invalid = %v, want zero", got)
+	}
+}
+
+func TestOrderID_JSON(t *testing.T) {
+	var v struct {
+		ID OrderID `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(`{"id":"816501678_20160919184316_1419533539"}`), &v); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(out) != `{"id":"816501678_20160919184316_1419533539"}` {
+		t.Errorf("Marshal() = %s", out)
+	}
+	// an order ID read from elsewhere may hold any byte, it must still marshal as valid JSON
+	for _, id := range []OrderID{"sn\x01_20160919184316_1", "sn\u00e9\x7f_20160919184316_1", "sn\xff_20160919184316_1"} {
+		out, err := json.Marshal(id)
+		if err != nil || !json.Valid(out) {
+			t.Errorf("Marshal(%q) = %s, %v, want valid JSON", string(id), out, err)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"id":"bad"}`), &v); err == nil {
+		t.Error("Unmarshal() of invalid order id succeeded")
+	}
+	if err := json.Unmarshal([]byte(`{"id":""}`), &v); err != nil || v.ID != "" {
+		t.Errorf("Unmarshal() of empty = %q, %v", v.ID, err)
+	}
+}
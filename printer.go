@@ -0,0 +1,281 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxPrinterAddList is the maximum number of printers Open_printerAddlist accepts per call.
+	// 每次最多添加100台。
+	MaxPrinterAddList = 100
+
+	// printerContentSep separates the fields of a printerContent line.
+	printerContentSep = "#"
+
+	// printerContentLineSep separates the printers of a printerContent value.
+	printerContentLineSep = "\n"
+)
+
+var (
+	// ErrEmptyPrinters is returned when no printer is given.
+	ErrEmptyPrinters = errors.New("feie: printers is empty")
+
+	// ErrTooManyPrinters is returned when more than MaxPrinterAddList printers are given in one request.
+	ErrTooManyPrinters = fmt.Errorf("feie: at most %d printers per request", MaxPrinterAddList)
+)
+
+// PrinterSpec describes one printer of Open_printerAddlist.
+// 打印机编号SN(必填) # 打印机识别码KEY(必填) # 备注名称(选填) # 流量卡号码(选填)
+type PrinterSpec struct {
+	SN         string `json:"sn" description:"打印机编号(必填)。"`
+	Key        string `json:"key" description:"打印机识别码(必填)。"`
+	Remark     string `json:"remark,omitempty" description:"备注名称(选填)。"`
+	CardNumber string `json:"cardNumber,omitempty" description:"流量卡号码(选填)。"`
+}
+
+// Validate checks the required fields and rejects the separators used by printerContent.
+func (s PrinterSpec) Validate() error {
+	if strings.TrimSpace(s.SN) == "" {
+		return errors.New("feie: printer sn is empty")
+	}
+	if strings.TrimSpace(s.Key) == "" {
+		return fmt.Errorf("feie: printer %s key is empty", s.SN)
+	}
+	for _, field := range [][2]string{
+		{"sn", s.SN},
+		{"key", s.Key},
+		{"remark", s.Remark},
+		{"cardNumber", s.CardNumber},
+	} {
+		if strings.ContainsAny(field[1], printerContentSep+"\r\n") {
+			return fmt.Errorf("feie: printer %s %s must not contain '#' or line breaks", s.SN, field[0])
+		}
+	}
+	return nil
+}
+
+// String returns the printerContent line of the printer: sn#key#remark#carnum.
+func (s PrinterSpec) String() string {
+	fields := []string{strings.TrimSpace(s.SN), strings.TrimSpace(s.Key)}
+	remark, cardNumber := strings.TrimSpace(s.Remark), strings.TrimSpace(s.CardNumber)
+	if remark != "" || cardNumber != "" {
+		fields = append(fields, remark)
+	}
+	if cardNumber != "" {
+		fields = append(fields, cardNumber)
+	}
+	return strings.Join(fields, printerContentSep)
+}
+
+// BuildPrinterContent builds the printerContent field of Open_printerAddlist.
+// Every printer is validated first, a printer containing '#' or a line break is rejected.
+func BuildPrinterContent(specs []PrinterSpec) (string, error) {
+	if len(specs) == 0 {
+		return "", ErrEmptyPrinters
+	}
+	if len(specs) > MaxPrinterAddList {
+		return "", ErrTooManyPrinters
+	}
+	lines := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		if err := spec.Validate(); err != nil {
+			return "", err
+		}
+		lines = append(lines, spec.String())
+	}
+	return strings.Join(lines, printerContentLineSep), nil
+}
+
+// PrinterResult is the result of one printer returned by Open_printerAddlist or Open_printerDelList.
+type PrinterResult struct {
+	PrinterSpec
+	OK     bool   `json:"ok" description:"是否成功。"`
+	Reason string `json:"reason,omitempty" description:"失败原因。"`
+}
+
+// AddResults parses the ok and no lists of Open_printerAddlist into per-SN results.
+// 正确例子：{"ok":["sn#key#remark#carnum"],"no":["316500012#abcdefgh#快餐前台#13688889999  （错误：识别码不正确）"]}
+func (d *PrinterRespData) AddResults() []*PrinterResult {
+	if d == nil {
+		return nil
+	}
+	results := make([]*PrinterResult, 0, len(d.Ok)+len(d.No))
+	for _, item := range d.Ok {
+		if item == nil {
+			continue
+		}
+		results = append(results, parseAddResult(*item, true))
+	}
+	for _, item := range d.No {
+		if item == nil {
+			continue
+		}
+		results = append(results, parseAddResult(*item, false))
+	}
+	return results
+}
+
+// parseAddResult parses one entry of the ok or no list of Open_printerAddlist, only an entry
+// of the no list carries a reason: a remark of an ok entry may end in parentheses too.
+func parseAddResult(item string, ok bool) *PrinterResult {
+	line, reason := strings.TrimSpace(item), ""
+	if !ok {
+		line, reason = splitReason(item)
+	}
+	fields := strings.SplitN(line, printerContentSep, 4)
+	result := &PrinterResult{OK: ok, Reason: reason}
+	for i, field := range fields {
+		field = strings.TrimSpace(field)
+		switch i {
+		case 0:
+			result.SN = field
+		case 1:
+			result.Key = field
+		case 2:
+			result.Remark = field
+		case 3:
+			result.CardNumber = field
+		}
+	}
+	if !ok && result.Reason == "" {
+		result.Reason = "unknown"
+	}
+	return result
+}
+
+// splitReason splits the trailing reason in parentheses, such as "（错误：识别码不正确）", from the item.
+func splitReason(item string) (string, string) {
+	item = strings.TrimSpace(item)
+	for _, pair := range [][2]string{{"（", "）"}, {"(", ")"}} {
+		if !strings.HasSuffix(item, pair[1]) {
+			continue
+		}
+		start := strings.LastIndex(item, pair[0])
+		if start < 0 {
+			continue
+		}
+		reason := item[start+len(pair[0]) : len(item)-len(pair[1])]
+		for _, prefix := range []string{"错误：", "错误:"} {
+			reason = strings.TrimPrefix(reason, prefix)
+		}
+		return strings.TrimSpace(item[:start]), strings.TrimSpace(reason)
+	}
+	return item, ""
+}
+
+// DelResults parses the ok and no lists of Open_printerDelList into per-SN results in the order of sns.
+// 正确例子：{"ok":["800000777成功","915500104成功"],"no":["800000777用户UID不匹配"]}
+func (d *PrinterRespData) DelResults(sns []string) []*PrinterResult {
+	results := make([]*PrinterResult, len(sns))
+	for i, sn := range sns {
+		results[i] = &PrinterResult{PrinterSpec: PrinterSpec{SN: sn}, Reason: "no result returned"}
+	}
+	if d == nil {
+		return results
+	}
+	for _, list := range []struct {
+		items []*string
+		ok    bool
+	}{{d.Ok, true}, {d.No, false}} {
+		for _, item := range list.items {
+			if item == nil {
+				continue
+			}
+			i := matchSN(sns, *item)
+			if i < 0 {
+				continue
+			}
+			results[i].OK = list.ok
+			results[i].Reason = ""
+			if !list.ok {
+				results[i].Reason = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(*item), sns[i]))
+			}
+		}
+	}
+	return results
+}
+
+// matchSN returns the index of the longest SN in sns the item starts with, or -1.
+func matchSN(sns []string, item string) int {
+	item = strings.TrimSpace(item)
+	index := -1
+	for i, sn := range sns {
+		if sn != "" && strings.HasPrefix(item, sn) && (index < 0 || len(sn) > len(sns[index])) {
+			index = i
+		}
+	}
+	return index
+}
+
+// PrinterStatus is the status returned by Open_queryPrinterStatus.
+type PrinterStatus int
+
+const (
+	// PrinterStatusUnknown is the status of a printer not queried yet or not recognized.
+	PrinterStatusUnknown PrinterStatus = iota
+	// PrinterStatusOffline 离线。
+	PrinterStatusOffline
+	// PrinterStatusOnline 在线，工作状态正常。
+	PrinterStatusOnline
+	// PrinterStatusAbnormal 在线，工作状态不正常，一般是无纸。
+	PrinterStatusAbnormal
+)
+
+// String returns the name of the status.
+func (s PrinterStatus) String() string {
+	switch s {
+	case PrinterStatusOffline:
+		return "offline"
+	case PrinterStatusOnline:
+		return "online"
+	case PrinterStatusAbnormal:
+		return "abnormal"
+	default:
+		return "unknown"
+	}
+}
+
+// Online reports whether the printer is connected to feieyun.
+func (s PrinterStatus) Online() bool {
+	return s == PrinterStatusOnline || s == PrinterStatusAbnormal
+}
+
+// Healthy reports whether the printer is online and works normally.
+func (s PrinterStatus) Healthy() bool {
+	return s == PrinterStatusOnline
+}
+
+// ParsePrinterStatus parses the data of Open_queryPrinterStatus:
+// 离线。/ 在线，工作状态正常。/ 在线，工作状态不正常。
+func ParsePrinterStatus(data string) PrinterStatus {
+	switch {
+	case strings.Contains(data, "离线"):
+		return PrinterStatusOffline
+	case strings.Contains(data, "不正常"), strings.Contains(data, "异常"):
+		return PrinterStatusAbnormal
+	case strings.Contains(data, "正常"):
+		return PrinterStatusOnline
+	default:
+		return PrinterStatusUnknown
+	}
+}
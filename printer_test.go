@@ -0,0 +1,121 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildPrinterContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []PrinterSpec
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "full",
+			specs: []PrinterSpec{
+				{SN: "sn1", Key: "key1", Remark: "remark1", CardNumber: "carnum1"},
+				{SN: "sn2", Key: "key2"},
+				{SN: "sn3", Key: "key3", CardNumber: "carnum3"},
+			},
+			want: "sn1#key1#remark1#carnum1\nsn2#key2\nsn3#key3##carnum3",
+		},
+		{
+			name:    "empty",
+			wantErr: true,
+		},
+		{
+			name:    "missing key",
+			specs:   []PrinterSpec{{SN: "sn1"}},
+			wantErr: true,
+		},
+		{
+			name:    "separator in remark",
+			specs:   []PrinterSpec{{SN: "sn1", Key: "key1", Remark: "front#desk"}},
+			wantErr: true,
+		},
+		{
+			name:    "line break in remark",
+			specs:   []PrinterSpec{{SN: "sn1", Key: "key1", Remark: "front\ndesk"}},
+			wantErr: true,
+		},
+		{
+			name:    "too many",
+			specs:   make([]PrinterSpec, MaxPrinterAddList+1),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildPrinterContent(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildPrinterContent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("BuildPrinterContent() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrinterRespData_AddResults(t *testing.T) {
+	var (
+		ok1 = "sn#key#remark#carnum"
+		ok2 = "316500011#abcdefgh#快餐前台"
+		ok3 = "316500014#abcdefgh#店铺(一楼)"
+		no1 = "316500012#abcdefgh#快餐前台#13688889999  （错误：识别码不正确）"
+		no2 = "316500013#abcdefgh (already added)"
+	)
+	data := &PrinterRespData{Ok: []*string{&ok1, &ok2, &ok3, nil}, No: []*string{&no1, &no2}}
+	want := []*PrinterResult{
+		{PrinterSpec: PrinterSpec{SN: "sn", Key: "key", Remark: "remark", CardNumber: "carnum"}, OK: true},
+		{PrinterSpec: PrinterSpec{SN: "316500011", Key: "abcdefgh", Remark: "快餐前台"}, OK: true},
+		{PrinterSpec: PrinterSpec{SN: "316500014", Key: "abcdefgh", Remark: "店铺(一楼)"}, OK: true},
+		{PrinterSpec: PrinterSpec{SN: "316500012", Key: "abcdefgh", Remark: "快餐前台", CardNumber: "13688889999"}, Reason: "识别码不正确"},
+		{PrinterSpec: PrinterSpec{SN: "316500013", Key: "abcdefgh"}, Reason: "already added"},
+	}
+	if got := data.AddResults(); !reflect.DeepEqual(got, want) {
+		t.Errorf("AddResults() got = %+v, want %+v", got, want)
+	}
+	if got := (*PrinterRespData)(nil).AddResults(); got != nil {
+		t.Errorf("AddResults() on nil got = %+v, want nil", got)
+	}
+}
+
+func TestParsePrinterStatus(t *testing.T) {
+	tests := []struct {
+		data string
+		want PrinterStatus
+	}{
+		{"离线。", PrinterStatusOffline},
+		{"在线，工作状态正常。", PrinterStatusOnline},
+		{"在线，工作状态不正常。", PrinterStatusAbnormal},
+		{"", PrinterStatusUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			if got := ParsePrinterStatus(tt.data); got != tt.want {
+				t.Errorf("ParsePrinterStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,716 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+var (
+	// ErrQueueClosed is returned when a job is enqueued after Close.
+	ErrQueueClosed = errors.New("feie: queue is closed")
+
+	// ErrJobNotFound is returned when the job does not exist.
+	ErrJobNotFound = errors.New("feie: job not found")
+)
+
+// JobKind is the API a Job is sent to.
+type JobKind string
+
+const (
+	// JobPrintMsg sends the job with Open_printMsg.
+	JobPrintMsg JobKind = "printMsg"
+	// JobPrintLabelMsg sends the job with Open_printLabelMsg.
+	JobPrintLabelMsg JobKind = "printLabelMsg"
+)
+
+// Job is a print job of Queue.
+type Job struct {
+	ID          string            `json:"id"`
+	Kind        JobKind           `json:"kind"`
+	Msg         *PrintMsgReq      `json:"msg,omitempty"`
+	Label       *PrintLabelMsgReq `json:"label,omitempty"`
+	Attempts    int               `json:"attempts"`
+	NextAttempt time.Time         `json:"nextAttempt"`
+	LastError   string            `json:"lastError,omitempty"`
+	OrderID     string            `json:"orderId,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	seq         uint64
+}
+
+// SN returns the printer the job is sent to.
+func (j *Job) SN() string {
+	if j.Kind == JobPrintLabelMsg && j.Label != nil {
+		return j.Label.SN
+	}
+	if j.Msg != nil {
+		return j.Msg.SN
+	}
+	return ""
+}
+
+// validate checks the job has the request of its kind.
+func (j *Job) validate() error {
+	switch {
+	case j.Kind == JobPrintMsg && j.Msg != nil:
+	case j.Kind == JobPrintLabelMsg && j.Label != nil:
+	default:
+		return fmt.Errorf("feie: invalid job kind %q or missing request", j.Kind)
+	}
+	return nil
+}
+
+// clone returns a copy of the job and its request, so the queue never shares them with the caller.
+func (j *Job) clone() *Job {
+	c := *j
+	if j.Msg != nil {
+		msg := *j.Msg
+		c.Msg = &msg
+	}
+	if j.Label != nil {
+		label := *j.Label
+		c.Label = &label
+	}
+	return &c
+}
+
+// expired returns the UNIX timestamp the job expires at, 0 if it never expires.
+func (j *Job) expired() int64 {
+	if j.Kind == JobPrintLabelMsg && j.Label != nil {
+		return j.Label.Expired
+	}
+	if j.Msg != nil {
+		return j.Msg.Expired
+	}
+	return 0
+}
+
+// walOp is the operation of a write-ahead log record.
+type walOp string
+
+const (
+	walPut  walOp = "put"
+	walAck  walOp = "ack"
+	walDead walOp = "dead"
+	walDrop walOp = "drop"
+)
+
+// walRecord is one line of the write-ahead log.
+type walRecord struct {
+	Op  walOp  `json:"op"`
+	ID  string `json:"id,omitempty"`
+	Job *Job   `json:"job,omitempty"`
+}
+
+// QueueOption is the option of Queue.
+type QueueOption func(q *Queue)
+
+// WithQueueWorkers sets the number of workers, default 4.
+func WithQueueWorkers(n int) QueueOption {
+	return func(q *Queue) {
+		if n > 0 {
+			q.workers = n
+		}
+	}
+}
+
+// WithQueueMaxAttempts sets the number of attempts before a job that was never sent is dead-lettered, default 10.
+func WithQueueMaxAttempts(n int) QueueOption {
+	return func(q *Queue) {
+		if n > 0 {
+			q.maxAttempts = n
+		}
+	}
+}
+
+// WithQueueBackoff sets the first and the maximum delay between two attempts of a job,
+// default 1 second and 5 minutes. The delay doubles after every attempt.
+func WithQueueBackoff(min, max time.Duration) QueueOption {
+	return func(q *Queue) {
+		if min > 0 && max >= min {
+			q.minBackoff, q.maxBackoff = min, max
+		}
+	}
+}
+
+// WithQueueClock sets the clock, default the system clock.
+func WithQueueClock(clock Clock) QueueOption {
+	return func(q *Queue) {
+		q.clock = clock
+	}
+}
+
+// WithQueueCallback calls fn when a job is acknowledged with err nil, or dead-lettered with its last error.
+func WithQueueCallback(fn func(ctx context.Context, job *Job, err error)) QueueOption {
+	return func(q *Queue) {
+		q.callback = fn
+	}
+}
+
+// Queue is a durable print job queue in front of Open_printMsg and Open_printLabelMsg.
+// Every change is appended to a write-ahead log file before it takes effect, so pending jobs
+// survive restarts. A job is acknowledged only after feieyun returns an order ID, which makes
+// the delivery at-least-once: a crash between the print and the acknowledgement resends the job.
+// Only the failures proving the request never reached feieyun, such as a dial error or a 503,
+// are retried with backoff. Any other failure goes to the dead-letter list: a non-zero ret, an
+// expired job, and a failure such as a timeout after which the ticket may be printed. A job
+// interrupted by Close or the end of the ctx of Start stays pending for the next run.
+type Queue struct {
+	client      *Client
+	path        string
+	workers     int
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	clock       Clock
+	callback    func(ctx context.Context, job *Job, err error)
+
+	mu       sync.Mutex
+	wal      *os.File
+	records  int
+	seq      uint64
+	pending  map[string]*Job
+	dead     map[string]*Job
+	inflight map[string]bool
+	wake     chan struct{}
+	closed   bool
+	draining bool
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// OpenQueue opens the queue whose write-ahead log is path, replaying the jobs left by a previous run.
+// Call Start to run the workers and Close to drain them.
+func OpenQueue(c *Client, path string, opts ...QueueOption) (*Queue, error) {
+	q := &Queue{
+		client:      c,
+		path:        path,
+		workers:     4,
+		maxAttempts: 10,
+		minBackoff:  time.Second,
+		maxBackoff:  5 * time.Minute,
+		clock:       systemClock{},
+		pending:     make(map[string]*Job),
+		dead:        make(map[string]*Job),
+		inflight:    make(map[string]bool),
+		wake:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	if err := q.replay(); err != nil {
+		return nil, err
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// replay rebuilds the pending and dead jobs from the write-ahead log.
+func (q *Queue) replay() error {
+	f, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var (
+		scanner = bufio.NewScanner(f)
+		line    int
+		bad     error
+	)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line++
+		if bad != nil {
+			return bad
+		}
+		var record walRecord
+		if err = sonic.Unmarshal(scanner.Bytes(), &record); err == nil {
+			err = record.validate()
+		}
+		if err != nil {
+			// a torn last line is left by a crash while appending, the record was never acknowledged.
+			// Anywhere else the log is corrupted and skipping the line could resend a printed job.
+			bad = fmt.Errorf("feie: queue log %s line %d: %w", q.path, line, err)
+			continue
+		}
+		q.apply(&record)
+	}
+	return scanner.Err()
+}
+
+// validate checks the record carries what its operation needs.
+func (r *walRecord) validate() error {
+	switch r.Op {
+	case walPut, walDead:
+		if r.Job == nil || r.Job.ID == "" {
+			return fmt.Errorf("%s record without job", r.Op)
+		}
+	case walAck, walDrop:
+		if r.ID == "" {
+			return fmt.Errorf("%s record without id", r.Op)
+		}
+	default:
+		return fmt.Errorf("unknown record op %q", r.Op)
+	}
+	return nil
+}
+
+// apply applies a validated write-ahead log record to the in-memory state.
+func (q *Queue) apply(record *walRecord) {
+	switch record.Op {
+	case walPut:
+		if job, ok := q.pending[record.Job.ID]; ok {
+			record.Job.seq = job.seq
+		} else {
+			q.seq++
+			record.Job.seq = q.seq
+		}
+		q.pending[record.Job.ID] = record.Job
+	case walAck:
+		delete(q.pending, record.ID)
+	case walDead:
+		delete(q.pending, record.Job.ID)
+		q.dead[record.Job.ID] = record.Job
+	case walDrop:
+		delete(q.dead, record.ID)
+	}
+}
+
+// compact rewrites the write-ahead log with the live jobs only.
+func (q *Queue) compact() error {
+	var (
+		tmp   = q.path + ".compact"
+		count int
+	)
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	write := func(record walRecord) error {
+		line, err := sonic.Marshal(record)
+		if err != nil {
+			return err
+		}
+		count++
+		_, err = w.Write(append(line, '\n'))
+		return err
+	}
+	for _, job := range q.sorted(q.pending) {
+		if err = write(walRecord{Op: walPut, Job: job}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	for _, job := range q.sorted(q.dead) {
+		if err = write(walRecord{Op: walDead, Job: job}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, q.path); err != nil {
+		return err
+	}
+	if q.wal != nil {
+		q.wal.Close()
+	}
+	if q.wal, err = os.OpenFile(q.path, os.O_APPEND|os.O_WRONLY, 0o600); err != nil {
+		return err
+	}
+	q.records = count
+	return nil
+}
+
+// append writes a record to the write-ahead log and applies it, q.mu must be held.
+func (q *Queue) append(record walRecord) error {
+	line, err := sonic.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err = q.wal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err = q.wal.Sync(); err != nil {
+		return err
+	}
+	q.apply(&record)
+	q.records++
+	if live := len(q.pending) + len(q.dead); q.records > 1024 && q.records > 4*live {
+		return q.compact()
+	}
+	return nil
+}
+
+// sorted returns the jobs in submission order.
+func (q *Queue) sorted(jobs map[string]*Job) []*Job {
+	list := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, job)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].seq < list[j].seq
+	})
+	return list
+}
+
+// EnqueueMsg enqueues a job sent with Open_printMsg and returns its ID.
+func (q *Queue) EnqueueMsg(ctx context.Context, req *PrintMsgReq) (string, error) {
+	return q.Enqueue(ctx, &Job{Kind: JobPrintMsg, Msg: req})
+}
+
+// EnqueueLabel enqueues a job sent with Open_printLabelMsg and returns its ID.
+func (q *Queue) EnqueueLabel(ctx context.Context, req *PrintLabelMsgReq) (string, error) {
+	return q.Enqueue(ctx, &Job{Kind: JobPrintLabelMsg, Label: req})
+}
+
+// Enqueue persists a copy of the job and returns its ID, a job without ID gets a random one.
+// The job is durable once Enqueue returns.
+func (q *Queue) Enqueue(_ context.Context, job *Job) (string, error) {
+	if err := job.validate(); err != nil {
+		return "", err
+	}
+	job = job.clone()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return "", ErrQueueClosed
+	}
+	if job.ID == "" {
+		job.ID = newID()
+	}
+	// the job ID is the default idempotency key, so a job resent after a crash is not printed
+	// twice as long as the IdempotencyStore of the client outlives the process.
+	if job.Msg != nil && job.Msg.IdempotencyKey == "" {
+		job.Msg.IdempotencyKey = job.ID
+	}
+	if job.Label != nil && job.Label.IdempotencyKey == "" {
+		job.Label.IdempotencyKey = job.ID
+	}
+	if _, ok := q.pending[job.ID]; ok {
+		return "", fmt.Errorf("feie: job %s already enqueued", job.ID)
+	}
+	now := q.clock.Now()
+	job.CreatedAt, job.NextAttempt = now, now
+	if err := q.append(walRecord{Op: walPut, Job: job}); err != nil {
+		return "", err
+	}
+	q.notify()
+	return job.ID, nil
+}
+
+// Start runs the workers until Close.
+func (q *Queue) Start(ctx context.Context) {
+	ctx, q.cancel = context.WithCancel(ctx)
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+}
+
+// Close stops accepting jobs and drains the queue: the workers keep sending the jobs that are
+// due until none is left or ctx is done, then the in-flight requests are canceled.
+// Jobs that are not acknowledged stay in the write-ahead log for the next run.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrQueueClosed
+	}
+	q.closed, q.draining = true, true
+	q.notify()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if q.cancel != nil {
+		q.cancel()
+	}
+	<-done
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if cerr := q.compact(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if cerr := q.wal.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Len returns the number of pending jobs.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Pending returns the pending jobs in submission order.
+func (q *Queue) Pending() []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return copyJobs(q.sorted(q.pending))
+}
+
+// DeadLetters returns the dead-lettered jobs in submission order.
+func (q *Queue) DeadLetters() []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return copyJobs(q.sorted(q.dead))
+}
+
+// Requeue moves a dead-lettered job back to the queue with its attempts reset.
+func (q *Queue) Requeue(_ context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	job, ok := q.dead[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if err := q.append(walRecord{Op: walDrop, ID: id}); err != nil {
+		return err
+	}
+	retry := *job
+	retry.Attempts, retry.LastError, retry.NextAttempt = 0, "", q.clock.Now()
+	if err := q.append(walRecord{Op: walPut, Job: &retry}); err != nil {
+		return err
+	}
+	q.notify()
+	return nil
+}
+
+// Discard removes a dead-lettered job.
+func (q *Queue) Discard(_ context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.dead[id]; !ok {
+		return ErrJobNotFound
+	}
+	return q.append(walRecord{Op: walDrop, ID: id})
+}
+
+// notify wakes the idle workers, q.mu must be held.
+func (q *Queue) notify() {
+	close(q.wake)
+	q.wake = make(chan struct{})
+}
+
+// next returns the next job due and marks it in flight, or how long to wait for one.
+// ok is false when the queue is draining and has no job due.
+func (q *Queue) next() (job *Job, wait time.Duration, wake <-chan struct{}, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var (
+		now      = q.clock.Now()
+		earliest *Job
+	)
+	for _, j := range q.pending {
+		if q.inflight[j.ID] {
+			continue
+		}
+		if earliest == nil || j.NextAttempt.Before(earliest.NextAttempt) ||
+			(j.NextAttempt.Equal(earliest.NextAttempt) && j.seq < earliest.seq) {
+			earliest = j
+		}
+	}
+	if earliest != nil && !earliest.NextAttempt.After(now) {
+		q.inflight[earliest.ID] = true
+		copied := *earliest
+		return &copied, 0, q.wake, true
+	}
+	if q.draining {
+		return nil, 0, q.wake, false
+	}
+	wait = -1
+	if earliest != nil {
+		wait = earliest.NextAttempt.Sub(now)
+	}
+	return nil, wait, q.wake, true
+}
+
+// work is the loop of a worker.
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+	for ctx.Err() == nil {
+		job, wait, wake, ok := q.next()
+		if !ok {
+			return
+		}
+		if job != nil {
+			q.process(ctx, job)
+			continue
+		}
+		var timer <-chan time.Time
+		if wait >= 0 {
+			timer = q.clock.After(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-timer:
+		}
+	}
+}
+
+// process sends the job once and records the outcome.
+func (q *Queue) process(ctx context.Context, job *Job) {
+	var (
+		orderID string
+		err     error
+		final   bool
+	)
+	if expired := job.expired(); expired > 0 && q.clock.Now().Unix() >= expired {
+		err, final = errors.New("feie: job expired before it was printed"), true
+	} else {
+		orderID, err = q.client.sendJob(ctx, job)
+		// an API error is final, and any other failure after the request may have reached
+		// feieyun is not retried either: the ticket may already be printed.
+		final = err != nil && !requestNotSent(err)
+	}
+
+	q.mu.Lock()
+	delete(q.inflight, job.ID)
+	if err != nil && ctx.Err() != nil {
+		// the queue is shutting down, the job stays pending in the log as it was.
+		q.mu.Unlock()
+		return
+	}
+	job.Attempts++
+	var werr error
+	switch {
+	case err == nil:
+		job.OrderID = orderID
+		werr = q.append(walRecord{Op: walAck, ID: job.ID})
+	case final || job.Attempts >= q.maxAttempts:
+		job.LastError = err.Error()
+		werr = q.append(walRecord{Op: walDead, Job: job})
+	default:
+		job.LastError = err.Error()
+		job.NextAttempt = q.clock.Now().Add(q.backoff(job.Attempts))
+		werr = q.append(walRecord{Op: walPut, Job: job})
+	}
+	q.mu.Unlock()
+
+	if werr != nil {
+		q.client.logEvent(ctx, hlog.LevelError, "feie queue write job to the log failed",
+			Field{Key: "job_id", Value: job.ID}, Field{Key: "sn", Value: job.SN()}, Field{Key: "error", Value: werr})
+	}
+	if err != nil && !final && job.Attempts < q.maxAttempts {
+		q.client.logEvent(ctx, hlog.LevelWarn, "feie queue job attempt failed",
+			Field{Key: "job_id", Value: job.ID}, Field{Key: "sn", Value: job.SN()},
+			Field{Key: "attempt", Value: job.Attempts}, Field{Key: "error", Value: err})
+		return
+	}
+	if q.callback != nil {
+		q.callback(ctx, job, err)
+	}
+}
+
+// backoff returns the delay before the next attempt after attempts attempts.
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.minBackoff
+	for i := 1; i < attempts && d < q.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > q.maxBackoff {
+		d = q.maxBackoff
+	}
+	return d
+}
+
+// sendJob sends the job and returns the order ID, a non-zero ret is returned as *APIError.
+func (c *Client) sendJob(ctx context.Context, job *Job) (string, error) {
+	if job.Kind == JobPrintLabelMsg {
+		resp, err := c.OpenPrintLabelMsg(ctx, job.Label)
+		if err != nil {
+			return "", err
+		}
+		if resp.Ret != 0 {
+			return "", &APIError{API: printLabelMsg, Ret: resp.Ret, Msg: resp.Msg}
+		}
+		return resp.Data, nil
+	}
+	resp, err := c.OpenPrintMsg(ctx, job.Msg)
+	if err != nil {
+		return "", err
+	}
+	if resp.Ret != 0 {
+		return "", &APIError{API: printMsg, Ret: resp.Ret, Msg: resp.Msg}
+	}
+	return resp.Data, nil
+}
+
+// copyJobs returns copies of the jobs.
+func copyJobs(jobs []*Job) []*Job {
+	copied := make([]*Job, len(jobs))
+	for i, job := range jobs {
+		copied[i] = job.clone()
+	}
+	return copied
+}
+
+// newID returns a random 32 characters hex ID.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,245 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	var (
+		down  int32 = 1
+		mu    sync.Mutex
+		sent  []string
+		ctx   = context.Background()
+		path  = filepath.Join(t.TempDir(), "queue.wal")
+		gwSrv = newTestGateway(t, func(form map[string]string) interface{} {
+			if form[SNField] == "bad" {
+				return map[string]interface{}{"ret": 1002, "msg": "打印机编号错误"}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, form[ContentField])
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_20160919184316_1419533539"}
+		})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		gwSrv.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	c := New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+
+	q, err := OpenQueue(c, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{"ticket 1", "ticket 2"} {
+		if _, err = q.EnqueueMsg(ctx, &PrintMsgReq{SN: "sn1", Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = q.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err = q.EnqueueMsg(ctx, &PrintMsgReq{SN: "sn1"}); err != ErrQueueClosed {
+		t.Errorf("EnqueueMsg() after Close error = %v, want %v", err, ErrQueueClosed)
+	}
+
+	var (
+		delivered = make(chan *Job, 3)
+		failed    = make(chan *Job, 1)
+	)
+	q, err = OpenQueue(c, path, WithQueueWorkers(1), WithQueueBackoff(10*time.Millisecond, 20*time.Millisecond),
+		WithQueueCallback(func(ctx context.Context, job *Job, err error) {
+			if err != nil {
+				failed <- job
+				return
+			}
+			delivered <- job
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Len() != 2 {
+		t.Fatalf("Len() after reopen = %d, want 2", q.Len())
+	}
+	if _, err = q.EnqueueMsg(ctx, &PrintMsgReq{SN: "bad", Content: "ticket 3"}); err != nil {
+		t.Fatal(err)
+	}
+	q.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+	atomic.StoreInt32(&down, 0)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case job := <-delivered:
+			if job.OrderID == "" || job.Attempts < 2 {
+				t.Errorf("delivered job = %+v, want an order ID after retries", job)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for delivery")
+		}
+	}
+	select {
+	case job := <-failed:
+		if job.Msg.SN != "bad" {
+			t.Errorf("dead-lettered job = %+v", job)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the dead letter")
+	}
+	if err = q.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if len(sent) != 2 || sent[0] != "ticket 1" || sent[1] != "ticket 2" {
+		t.Errorf("sent = %v, want tickets 1 and 2 in order", sent)
+	}
+	mu.Unlock()
+
+	if q, err = OpenQueue(c, path); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close(ctx)
+	dead := q.DeadLetters()
+	if q.Len() != 0 || len(dead) != 1 {
+		t.Fatalf("after reopen pending = %d, dead = %d, want 0 and 1", q.Len(), len(dead))
+	}
+	if err = q.Requeue(ctx, dead[0].ID); err != nil || q.Len() != 1 || len(q.DeadLetters()) != 0 {
+		t.Errorf("Requeue() error = %v, pending = %d", err, q.Len())
+	}
+}
+
+func TestQueue_AmbiguousFailure(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+	c := New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+	failed := make(chan *Job, 1)
+	q, err := OpenQueue(c, filepath.Join(t.TempDir(), "queue.wal"), WithQueueBackoff(time.Millisecond, time.Millisecond),
+		WithQueueCallback(func(ctx context.Context, job *Job, err error) { failed <- job }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close(ctx)
+	if _, err = q.EnqueueMsg(ctx, &PrintMsgReq{SN: "sn1", Content: "ticket"}); err != nil {
+		t.Fatal(err)
+	}
+	q.Start(ctx)
+	select {
+	case job := <-failed:
+		if job.Attempts != 1 || atomic.LoadInt32(&calls) != 1 {
+			t.Errorf("dead-lettered after %d attempts and %d calls, want 1", job.Attempts, calls)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the dead letter")
+	}
+}
+
+func TestQueue_Replay(t *testing.T) {
+	put := `{"op":"put","job":{"id":"j1","kind":"printMsg","msg":{"sn":"sn1","content":"ticket"}}}`
+	tests := []struct {
+		name    string
+		log     string
+		pending int
+		wantErr bool
+	}{
+		{"torn last line", put + "\n" + `{"op":"ack","id":`, 1, false},
+		{"corrupted ack", put + "\n" + `{"op":"ack",` + "\n" + `{"op":"drop","id":"j2"}` + "\n", 0, true},
+		{"put without job", `{"op":"put"}` + "\n" + put + "\n", 0, true},
+		{"dead without job", put + "\n" + `{"op":"dead"}` + "\n" + put + "\n", 0, true},
+		{"unknown op", `{"op":"move","id":"j1"}` + "\n" + put + "\n", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "queue.wal")
+			if err := os.WriteFile(path, []byte(tt.log), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			q, err := OpenQueue(New(context.Background()), path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenQueue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer q.Close(context.Background())
+			if q.Len() != tt.pending {
+				t.Errorf("Len() = %d, want %d", q.Len(), tt.pending)
+			}
+		})
+	}
+}
+
+func TestQueue_CloseInterrupted(t *testing.T) {
+	started := make(chan struct{}, 1)
+	transport := TransportFunc(func(ctx context.Context, gateway string, form map[string]string) ([]byte, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	var (
+		ctx  = context.Background()
+		c    = New(ctx, WithUser("user"), WithUserKey("ukey"), WithTransport(transport))
+		path = filepath.Join(t.TempDir(), "queue.wal")
+		job  = &Job{Kind: JobPrintMsg, Msg: &PrintMsgReq{SN: "sn1", Content: "ticket"}}
+	)
+	q, err := OpenQueue(c, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = q.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "" || job.Msg.IdempotencyKey != "" || job.Attempts != 0 {
+		t.Errorf("Enqueue() changed the job of the caller: %+v %+v", job, job.Msg)
+	}
+	q.Start(ctx)
+	<-started
+	closeCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err = q.Close(closeCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if q, err = OpenQueue(c, path); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close(ctx)
+	if pending, dead := q.Pending(), q.DeadLetters(); len(pending) != 1 || pending[0].Attempts != 0 || len(dead) != 0 {
+		t.Errorf("after an interrupted Close pending = %d, dead = %d, want the job pending", len(pending), len(dead))
+	}
+}
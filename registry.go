@@ -0,0 +1,233 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTenantNotFound is returned by a TenantProvider that has no credentials for the tenant.
+var ErrTenantNotFound = errors.New("feie: tenant not found")
+
+// Credentials are the feieyun user and UKEY requests are signed with.
+type Credentials struct {
+	User string `json:"user"`
+	UKey string `json:"ukey"`
+}
+
+// Validate checks the user and ukey are set.
+func (c Credentials) Validate() error {
+	if strings.TrimSpace(c.User) == "" {
+		return errors.New("feie: credentials user is empty")
+	}
+	if strings.TrimSpace(c.UKey) == "" {
+		return errors.New("feie: credentials ukey is empty")
+	}
+	return nil
+}
+
+// TenantProvider resolves a tenant ID to its feieyun credentials, such as from a database or a vault.
+type TenantProvider interface {
+	Credentials(ctx context.Context, tenantID string) (Credentials, error)
+}
+
+// TenantProviderFunc adapts a func to TenantProvider.
+type TenantProviderFunc func(ctx context.Context, tenantID string) (Credentials, error)
+
+// Credentials calls f.
+func (f TenantProviderFunc) Credentials(ctx context.Context, tenantID string) (Credentials, error) {
+	return f(ctx, tenantID)
+}
+
+// RegistryOption is the option of Registry.
+type RegistryOption func(r *Registry)
+
+// WithRegistryTTL sets how long resolved credentials are cached, default 5 minutes.
+func WithRegistryTTL(ttl time.Duration) RegistryOption {
+	return func(r *Registry) {
+		if ttl > 0 {
+			r.ttl = ttl
+		}
+	}
+}
+
+// WithRegistryResolveTimeout bounds a call to the TenantProvider, default 10 seconds. The call is
+// shared by every caller waiting for the tenant, so it does not run on the ctx of any of them.
+func WithRegistryResolveTimeout(timeout time.Duration) RegistryOption {
+	return func(r *Registry) {
+		if timeout > 0 {
+			r.resolveTimeout = timeout
+		}
+	}
+}
+
+// WithRegistryClock sets the clock, default the system clock.
+func WithRegistryClock(clock Clock) RegistryOption {
+	return func(r *Registry) {
+		r.clock = clock
+	}
+}
+
+// WithRegistryClientOptions sets the options every tenant client is built with, such as WithGateway.
+// WithUser, WithUserKey and WithCredentialsProvider are overridden by the credentials of the tenant.
+func WithRegistryClientOptions(opts ...Option) RegistryOption {
+	return func(r *Registry) {
+		r.opts = append(r.opts, opts...)
+	}
+}
+
+// registryEntry is the cached client of a tenant.
+type registryEntry struct {
+	creds   Credentials
+	client  *Client
+	expires time.Time
+}
+
+// registryCall is an in-flight resolution of a tenant, concurrent lookups wait for it.
+type registryCall struct {
+	done  chan struct{}
+	entry *registryEntry
+	err   error
+}
+
+// Registry gives every tenant of a SaaS platform its own client signed with the tenant's credentials.
+// A tenant client always signs with its tenant's credentials: req.User, SetUserKey and Reset have no
+// effect on it, so credentials never leak between tenants or concurrent calls.
+type Registry struct {
+	provider       TenantProvider
+	opts           []Option
+	ttl            time.Duration
+	resolveTimeout time.Duration
+	clock          Clock
+	logger         Logger
+
+	mu      sync.Mutex
+	entries map[string]*registryEntry
+	calls   map[string]*registryCall
+	swept   time.Time
+}
+
+// NewRegistry returns a registry resolving tenants with provider.
+func NewRegistry(provider TenantProvider, opts ...RegistryOption) *Registry {
+	r := &Registry{
+		provider:       provider,
+		ttl:            5 * time.Minute,
+		resolveTimeout: 10 * time.Second,
+		clock:          systemClock{},
+		entries:        make(map[string]*registryEntry),
+		calls:          make(map[string]*registryCall),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	// one logger for every tenant, a log file is opened once rather than once per tenant.
+	r.logger = newLogger(newOptions(r.opts...))
+	r.swept = r.clock.Now()
+	return r
+}
+
+// Client returns the client of the tenant, resolving its credentials when they are not cached or
+// expired. The client is reused while the credentials stay the same. ctx only bounds the wait of
+// this caller: the resolution goes on for the other callers of the tenant when ctx is done.
+func (r *Registry) Client(ctx context.Context, tenantID string) (*Client, error) {
+	r.mu.Lock()
+	if e, ok := r.entries[tenantID]; ok && r.clock.Now().Before(e.expires) {
+		r.mu.Unlock()
+		return e.client, nil
+	}
+	call, ok := r.calls[tenantID]
+	if !ok {
+		call = &registryCall{done: make(chan struct{})}
+		r.calls[tenantID] = call
+		go r.resolve(tenantID, call)
+	}
+	r.mu.Unlock()
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.entry.client, nil
+}
+
+// resolve resolves the tenant and caches its client, bounded by the resolve timeout only.
+func (r *Registry) resolve(tenantID string, call *registryCall) {
+	defer close(call.done)
+	ctx, cancel := context.WithTimeout(context.Background(), r.resolveTimeout)
+	defer cancel()
+	creds, err := r.provider.Credentials(ctx, tenantID)
+	if err == nil {
+		err = creds.Validate()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.calls, tenantID)
+	r.sweep(tenantID)
+	if err != nil {
+		call.err = err
+		return
+	}
+	entry := r.entries[tenantID]
+	if entry == nil || entry.creds != creds {
+		entry = &registryEntry{creds: creds, client: r.newClient(creds)}
+		r.entries[tenantID] = entry
+	}
+	entry.expires = r.clock.Now().Add(r.ttl)
+	call.entry = entry
+}
+
+// sweep drops the expired entries of the other tenants at most once per TTL, so the tenants
+// no longer used do not stay in memory. r.mu must be held.
+func (r *Registry) sweep(tenantID string) {
+	now := r.clock.Now()
+	if now.Sub(r.swept) < r.ttl {
+		return
+	}
+	r.swept = now
+	for id, e := range r.entries {
+		if id != tenantID && !now.Before(e.expires) {
+			delete(r.entries, id)
+		}
+	}
+}
+
+// newClient returns a client scoped to the credentials, logging to the logger of the registry.
+func (r *Registry) newClient(creds Credentials) *Client {
+	opts := append(append([]Option{}, r.opts...), WithUser(creds.User), WithUserKey(creds.UKey), WithCredentialsProvider(nil),
+		func(o *options) {
+			o.LogFile, o.LogPath, o.Logger = nil, "", r.logger
+		})
+	c := New(context.Background(), opts...)
+	c.scoped = true
+	return c
+}
+
+// Invalidate forgets the cached client of the tenant, the next Client call resolves it again.
+func (r *Registry) Invalidate(tenantID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, tenantID)
+}
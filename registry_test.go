@@ -0,0 +1,219 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTenantGateway starts a gateway accepting the users of ukeys and echoing the user in data.
+func newTenantGateway(t *testing.T, ukeys map[string]string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		user, stime := r.MultipartForm.Value[UserField][0], r.MultipartForm.Value[SysTimeField][0]
+		mu.Lock()
+		ukey, ok := ukeys[user]
+		mu.Unlock()
+		if !ok || r.MultipartForm.Value[SigField][0] != sha1Sign(user, ukey, stime) {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ret": -2, "msg": "签名错误"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"ret": 0, "msg": "ok", "data": user})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRegistry_Client(t *testing.T) {
+	ctx := context.Background()
+	ukeys := map[string]string{}
+	for i := 0; i < 8; i++ {
+		ukeys["user"+strconv.Itoa(i)] = "ukey" + strconv.Itoa(i)
+	}
+	srv := newTenantGateway(t, ukeys)
+	var resolved int32
+	provider := TenantProviderFunc(func(ctx context.Context, tenantID string) (Credentials, error) {
+		atomic.AddInt32(&resolved, 1)
+		if tenantID == "missing" {
+			return Credentials{}, ErrTenantNotFound
+		}
+		return Credentials{User: "user" + tenantID, UKey: "ukey" + tenantID}, nil
+	})
+	r := NewRegistry(provider, WithRegistryClientOptions(WithGateway(srv.URL)))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8*20)
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 20; j++ {
+			wg.Add(1)
+			go func(tenant string) {
+				defer wg.Done()
+				c, err := r.Client(ctx, tenant)
+				if err != nil {
+					errs <- err
+					return
+				}
+				// req.User of another tenant must not change the signing user.
+				resp, err := c.OpenQueryPrinterStatus(ctx, &QueryPrinterStatusReq{User: "user0", SN: "sn"})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if resp.Ret != 0 || resp.Data != "user"+tenant {
+					errs <- errors.New("tenant " + tenant + " signed as " + resp.Data + ": " + resp.Msg)
+				}
+			}(strconv.Itoa(i))
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := atomic.LoadInt32(&resolved); got != 8 {
+		t.Errorf("provider called %d times, want 8", got)
+	}
+	if _, err := r.Client(ctx, "missing"); !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("Client(missing) error = %v, want ErrTenantNotFound", err)
+	}
+}
+
+func TestRegistry_TTL(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	ukey := "old"
+	var resolved int32
+	provider := TenantProviderFunc(func(ctx context.Context, tenantID string) (Credentials, error) {
+		atomic.AddInt32(&resolved, 1)
+		return Credentials{User: "user", UKey: ukey}, nil
+	})
+	r := NewRegistry(provider, WithRegistryTTL(time.Minute), WithRegistryClock(clock))
+
+	first, _ := r.Client(ctx, "t")
+	clock.Advance(30 * time.Second)
+	if c, _ := r.Client(ctx, "t"); c != first || atomic.LoadInt32(&resolved) != 1 {
+		t.Fatalf("cached client changed or resolved %d times", resolved)
+	}
+	clock.Advance(time.Minute)
+	if c, _ := r.Client(ctx, "t"); c != first || atomic.LoadInt32(&resolved) != 2 {
+		t.Fatalf("unchanged credentials replaced the client or resolved %d times", resolved)
+	}
+	ukey = "new"
+	r.Invalidate("t")
+	rotated, _ := r.Client(ctx, "t")
+	if rotated == first {
+		t.Fatal("rotated credentials reused the old client")
+	}
+	if _, got := rotated.credentials(""); got != "new" {
+		t.Errorf("rotated client ukey = %q, want new", got)
+	}
+	rotated.SetUserKey("hijacked")
+	rotated.Reset()
+	if _, got := rotated.credentials(""); got != "new" {
+		t.Errorf("SetUserKey changed a tenant client ukey to %q", got)
+	}
+}
+
+func TestRegistry_CallerCanceled(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		provErr = make(chan error, 1)
+	)
+	provider := TenantProviderFunc(func(ctx context.Context, tenantID string) (Credentials, error) {
+		if tenantID == "slow" {
+			<-ctx.Done()
+			return Credentials{}, ctx.Err()
+		}
+		close(started)
+		<-release
+		provErr <- ctx.Err()
+		return Credentials{User: "user", UKey: "ukey"}, nil
+	})
+	r := NewRegistry(provider, WithRegistryResolveTimeout(50*time.Millisecond))
+
+	// the first caller gives up, the resolution it started still serves the second one
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := r.Client(ctx, "t")
+		first <- err
+	}()
+	<-started
+	second := make(chan error, 1)
+	go func() {
+		_, err := r.Client(context.Background(), "t")
+		second <- err
+	}()
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Client() of the canceled caller error = %v, want %v", err, context.Canceled)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("Client() of the waiting caller error = %v", err)
+	}
+	if err := <-provErr; err != nil {
+		t.Errorf("provider ctx error = %v, want the caller's cancel not to reach it", err)
+	}
+
+	if _, err := r.Client(context.Background(), "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Client(slow) error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRegistry_SweepAndLogger(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	provider := TenantProviderFunc(func(ctx context.Context, tenantID string) (Credentials, error) {
+		return Credentials{User: "user" + tenantID, UKey: "ukey"}, nil
+	})
+	r := NewRegistry(provider, WithRegistryTTL(time.Minute), WithRegistryClock(clock),
+		WithRegistryClientOptions(WithLogPath(t.TempDir())))
+
+	a, _ := r.Client(ctx, "a")
+	b, _ := r.Client(ctx, "b")
+	if a.log() != b.log() {
+		t.Error("tenant clients got their own logger, want the one of the registry")
+	}
+	clock.Advance(time.Minute)
+	if _, err := r.Client(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	n := len(r.entries)
+	r.mu.Unlock()
+	if n != 1 {
+		t.Errorf("entries after the TTL = %d, want only c", n)
+	}
+}
@@ -0,0 +1,433 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+var (
+	// ErrScheduleNotFound is returned when the scheduled job does not exist.
+	ErrScheduleNotFound = errors.New("feie: scheduled job not found")
+
+	// ErrScheduleExpired is reported when a scheduled job is due after its expiry, usually after a restart.
+	ErrScheduleExpired = errors.New("feie: scheduled job expired before it fired")
+)
+
+// ScheduledJob is a print job held locally until FireAt.
+type ScheduledJob struct {
+	ID     string    `json:"id"`
+	FireAt time.Time `json:"fireAt"`
+	// ExpireAfter is how long after FireAt the ticket may still print, the request is sent
+	// with expired set to FireAt+ExpireAfter. 0 uses the default of the Scheduler.
+	ExpireAfter time.Duration `json:"expireAfter"`
+	Job         Job           `json:"job"`
+	CreatedAt   time.Time     `json:"createdAt"`
+}
+
+// ScheduleStore persists the pending jobs of Scheduler.
+type ScheduleStore interface {
+	// Save creates or replaces the job.
+	Save(ctx context.Context, job *ScheduledJob) error
+	// Delete removes the job.
+	Delete(ctx context.Context, id string) error
+	// List returns all jobs.
+	List(ctx context.Context) ([]*ScheduledJob, error)
+}
+
+// MemoryScheduleStore is a ScheduleStore kept in memory.
+type MemoryScheduleStore struct {
+	mu   sync.RWMutex
+	jobs map[string]ScheduledJob
+}
+
+// NewMemoryScheduleStore returns a new MemoryScheduleStore.
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{jobs: make(map[string]ScheduledJob)}
+}
+
+// Save creates or replaces the job.
+func (s *MemoryScheduleStore) Save(_ context.Context, job *ScheduledJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+// Delete removes the job.
+func (s *MemoryScheduleStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+// List returns all jobs sorted by fire time.
+func (s *MemoryScheduleStore) List(_ context.Context) ([]*ScheduledJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jobs := make([]*ScheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		job := job
+		jobs = append(jobs, &job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].FireAt.Before(jobs[j].FireAt)
+	})
+	return jobs, nil
+}
+
+// FileScheduleStore is a ScheduleStore kept in memory and written to a JSON file on every change.
+type FileScheduleStore struct {
+	path   string
+	memory *MemoryScheduleStore
+	mu     sync.Mutex
+}
+
+// NewFileScheduleStore returns a new FileScheduleStore loading the jobs from path if it exists.
+func NewFileScheduleStore(path string) (*FileScheduleStore, error) {
+	s := &FileScheduleStore{path: path, memory: NewMemoryScheduleStore()}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = sonic.Unmarshal(content, &s.memory.jobs); err != nil {
+		return nil, err
+	}
+	if s.memory.jobs == nil {
+		s.memory.jobs = make(map[string]ScheduledJob)
+	}
+	return s, nil
+}
+
+// Save creates or replaces the job.
+func (s *FileScheduleStore) Save(ctx context.Context, job *ScheduledJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.memory.Save(ctx, job)
+	return s.flush()
+}
+
+// Delete removes the job.
+func (s *FileScheduleStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.memory.Delete(ctx, id)
+	return s.flush()
+}
+
+// List returns all jobs sorted by fire time.
+func (s *FileScheduleStore) List(ctx context.Context) ([]*ScheduledJob, error) {
+	return s.memory.List(ctx)
+}
+
+// flush writes all jobs to the file.
+func (s *FileScheduleStore) flush() error {
+	s.memory.mu.RLock()
+	content, err := sonic.ConfigStd.Marshal(s.memory.jobs)
+	s.memory.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, content)
+}
+
+// SchedulerOption is the option of Scheduler.
+type SchedulerOption func(s *Scheduler)
+
+// WithSchedulerTick sets the resolution of the timer wheel, default 1 second.
+func WithSchedulerTick(d time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		if d > 0 {
+			s.tick = d
+		}
+	}
+}
+
+// WithSchedulerSlots sets the number of slots of the timer wheel, default 3600.
+func WithSchedulerSlots(n int) SchedulerOption {
+	return func(s *Scheduler) {
+		if n > 0 {
+			s.slots = n
+		}
+	}
+}
+
+// WithSchedulerExpireAfter sets the default ExpireAfter of the jobs, default 10 minutes.
+func WithSchedulerExpireAfter(d time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		if d > 0 {
+			s.expireAfter = d
+		}
+	}
+}
+
+// WithSchedulerClock sets the clock, default the system clock.
+func WithSchedulerClock(clock Clock) SchedulerOption {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithSchedulerSubmit replaces how a due job is sent, such as Queue.Enqueue or Dispatcher.Submit,
+// default Open_printMsg or Open_printLabelMsg. It returns the order ID when known.
+func WithSchedulerSubmit(fn func(ctx context.Context, job *Job) (string, error)) SchedulerOption {
+	return func(s *Scheduler) {
+		s.submit = fn
+	}
+}
+
+// WithSchedulerCallback calls fn when a job fired with its order ID, or failed with err.
+// A failed job is not retried, submit through a Queue with WithSchedulerSubmit for retries.
+func WithSchedulerCallback(fn func(ctx context.Context, job *ScheduledJob, orderID string, err error)) SchedulerOption {
+	return func(s *Scheduler) {
+		s.callback = fn
+	}
+}
+
+// wheelEntry is a job placed in the timer wheel.
+type wheelEntry struct {
+	job    *ScheduledJob
+	slot   int
+	rounds int
+}
+
+// Scheduler holds print jobs locally and submits them at their fire time. The jobs are placed
+// in a hashed timer wheel advanced every tick, and persisted in a ScheduleStore so they
+// survive restarts.
+type Scheduler struct {
+	client      *Client
+	store       ScheduleStore
+	tick        time.Duration
+	slots       int
+	expireAfter time.Duration
+	clock       Clock
+	submit      func(ctx context.Context, job *Job) (string, error)
+	callback    func(ctx context.Context, job *ScheduledJob, orderID string, err error)
+
+	mu      sync.Mutex
+	wheel   []map[string]*wheelEntry
+	entries map[string]*wheelEntry
+	cursor  int
+	at      time.Time // the time the cursor stands for
+	wg      sync.WaitGroup
+}
+
+// NewScheduler returns a new Scheduler loading the pending jobs of store, a nil store is a MemoryScheduleStore.
+func NewScheduler(ctx context.Context, c *Client, store ScheduleStore, opts ...SchedulerOption) (*Scheduler, error) {
+	if store == nil {
+		store = NewMemoryScheduleStore()
+	}
+	s := &Scheduler{
+		client:      c,
+		store:       store,
+		tick:        time.Second,
+		slots:       3600,
+		expireAfter: 10 * time.Minute,
+		clock:       systemClock{},
+		entries:     make(map[string]*wheelEntry),
+	}
+	s.submit = c.sendJob
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.wheel = make([]map[string]*wheelEntry, s.slots)
+	for i := range s.wheel {
+		s.wheel[i] = make(map[string]*wheelEntry)
+	}
+	s.at = s.clock.Now()
+	jobs, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		s.place(job)
+	}
+	return s, nil
+}
+
+// Schedule holds the job until fireAt and returns its ID, a job without ID gets a random one.
+func (s *Scheduler) Schedule(ctx context.Context, job *ScheduledJob) (string, error) {
+	if err := job.Job.validate(); err != nil {
+		return "", err
+	}
+	if job.ID == "" {
+		job.ID = newID()
+	}
+	if job.ExpireAfter <= 0 {
+		job.ExpireAfter = s.expireAfter
+	}
+	job.CreatedAt = s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[job.ID]; ok {
+		return "", errors.New("feie: scheduled job " + job.ID + " already exists")
+	}
+	if err := s.store.Save(ctx, job); err != nil {
+		return "", err
+	}
+	s.place(job)
+	return job.ID, nil
+}
+
+// Cancel removes the scheduled job, ErrScheduleNotFound if it fired or does not exist.
+func (s *Scheduler) Cancel(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+	if err := s.store.Delete(ctx, id); err != nil {
+		return err
+	}
+	delete(s.wheel[entry.slot], id)
+	delete(s.entries, id)
+	return nil
+}
+
+// Pending returns the jobs waiting to fire sorted by fire time.
+func (s *Scheduler) Pending() []*ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*ScheduledJob, 0, len(s.entries))
+	for _, entry := range s.entries {
+		job := *entry.job
+		jobs = append(jobs, &job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].FireAt.Before(jobs[j].FireAt)
+	})
+	return jobs
+}
+
+// place puts the job in the slot it fires at, s.mu must be held unless the wheel is not running yet.
+// The slot is counted from the time of the cursor, not from now, since the wheel may lag behind.
+func (s *Scheduler) place(job *ScheduledJob) {
+	ticks := int((job.FireAt.Sub(s.at) + s.tick - 1) / s.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+	entry := &wheelEntry{
+		job:    job,
+		slot:   (s.cursor + ticks) % s.slots,
+		rounds: (ticks - 1) / s.slots,
+	}
+	s.wheel[entry.slot][job.ID] = entry
+	s.entries[job.ID] = entry
+}
+
+// Run advances the wheel every tick until ctx is done, then waits for the jobs being
+// submitted and returns ctx.Err(). The wheel follows the clock: a late wake-up, such as the
+// time before Run is called or spent firing jobs, advances it by every tick elapsed.
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer s.wg.Wait()
+	for {
+		s.mu.Lock()
+		wait := s.at.Add(s.tick).Sub(s.clock.Now())
+		s.mu.Unlock()
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-s.clock.After(wait):
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, job := range s.advance(s.clock.Now()) {
+			s.wg.Add(1)
+			go s.fire(ctx, job)
+		}
+	}
+}
+
+// advance moves the cursor by every tick elapsed until now and returns the jobs due.
+func (s *Scheduler) advance(now time.Time) []*ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*ScheduledJob
+	for !s.at.Add(s.tick).After(now) {
+		s.at = s.at.Add(s.tick)
+		s.cursor = (s.cursor + 1) % s.slots
+		for id, entry := range s.wheel[s.cursor] {
+			if entry.rounds > 0 {
+				entry.rounds--
+				continue
+			}
+			delete(s.wheel[s.cursor], id)
+			delete(s.entries, id)
+			due = append(due, entry.job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].FireAt.Before(due[j].FireAt)
+	})
+	return due
+}
+
+// fire submits the job with expired set relative to its fire time and removes it from the store.
+func (s *Scheduler) fire(ctx context.Context, job *ScheduledJob) {
+	defer s.wg.Done()
+	var (
+		orderID string
+		err     error
+		expired = job.FireAt.Add(job.ExpireAfter)
+		request = job.Job
+	)
+	if ctx.Err() != nil {
+		// stopped before the job was sent, it stays in the store for the next run.
+		return
+	}
+	if !s.clock.Now().Before(expired) {
+		err = ErrScheduleExpired
+	} else {
+		if request.Msg != nil {
+			msg := *request.Msg
+			msg.Expired, request.Msg = expired.Unix(), &msg
+		}
+		if request.Label != nil {
+			label := *request.Label
+			label.Expired, request.Label = expired.Unix(), &label
+		}
+		orderID, err = s.submit(ctx, &request)
+	}
+	if derr := s.store.Delete(ctx, job.ID); derr != nil {
+		s.client.logEvent(ctx, hlog.LevelError, "feie scheduler delete job failed",
+			Field{Key: "job_id", Value: job.ID}, Field{Key: "sn", Value: job.Job.SN()}, Field{Key: "error", Value: derr})
+	}
+	if err != nil {
+		s.client.logEvent(ctx, hlog.LevelWarn, "feie scheduler job failed",
+			Field{Key: "job_id", Value: job.ID}, Field{Key: "sn", Value: job.Job.SN()}, Field{Key: "error", Value: err})
+	}
+	if s.callback != nil {
+		s.callback(ctx, job, orderID, err)
+	}
+}
@@ -0,0 +1,167 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	var (
+		forms = make(chan map[string]string, 4)
+		fired = make(chan string, 4)
+	)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		forms <- form
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_20160919184316_1419533539"}
+	})
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		clock       = newFakeClock()
+		c           = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		path        = filepath.Join(t.TempDir(), "schedules.json")
+		opts        = []SchedulerOption{
+			WithSchedulerClock(clock), WithSchedulerSlots(60), WithSchedulerExpireAfter(5 * time.Minute),
+			WithSchedulerCallback(func(ctx context.Context, job *ScheduledJob, orderID string, err error) {
+				fired <- job.ID + " " + orderID + " " + strconv.FormatBool(err == nil)
+			}),
+		}
+	)
+	defer cancel()
+	clock.now = time.Now().Truncate(time.Second)
+	store, err := NewFileScheduleStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScheduler(ctx, c, store, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireAt := clock.Now().Add(90 * time.Second)
+	for _, id := range []string{"pickup", "canceled", "restart"} {
+		if _, err = s.Schedule(ctx, &ScheduledJob{ID: id, FireAt: fireAt, Job: Job{Kind: JobPrintMsg, Msg: &PrintMsgReq{SN: "sn1", Content: id}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.Cancel(ctx, "canceled"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second scheduler on the same store sees the jobs left by the first one
+	store, err = NewFileScheduleStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err = NewScheduler(ctx, c, store, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if pending := s.Pending(); len(pending) != 2 {
+		t.Fatalf("Pending() after restart = %d jobs, want 2", len(pending))
+	}
+	if err = s.Cancel(ctx, "restart"); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = s.Run(ctx)
+	}()
+	for i := 0; i < 89; i++ {
+		clock.BlockUntil(t, 1)
+		clock.Advance(time.Second)
+	}
+	select {
+	case got := <-fired:
+		t.Fatalf("job fired early: %s", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.BlockUntil(t, 1)
+	clock.Advance(time.Second)
+	select {
+	case got := <-fired:
+		if got != "pickup sn1_20160919184316_1419533539 true" {
+			t.Errorf("fired = %s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the job to fire")
+	}
+	form := <-forms
+	if want := strconv.FormatInt(fireAt.Add(5*time.Minute).Unix(), 10); form[ExpiredField] != want {
+		t.Errorf("expired = %s, want %s", form[ExpiredField], want)
+	}
+	if jobs, _ := store.List(ctx); len(jobs) != 0 {
+		t.Errorf("store after firing = %d jobs, want 0", len(jobs))
+	}
+}
+
+func TestScheduler_LateWakeUp(t *testing.T) {
+	fired := make(chan string, 1)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[SNField] + "_1"}
+	})
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		clock       = newFakeClock()
+		c           = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+	)
+	defer cancel()
+	clock.now = time.Now().Truncate(time.Second)
+	store, err := NewFileScheduleStore(filepath.Join(t.TempDir(), "schedules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScheduler(ctx, c, store, WithSchedulerClock(clock), WithSchedulerSlots(60),
+		WithSchedulerCallback(func(ctx context.Context, job *ScheduledJob, orderID string, err error) {
+			fired <- orderID
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Schedule(ctx, &ScheduledJob{ID: "pickup", FireAt: clock.Now().Add(10 * time.Second), Job: Job{Kind: JobPrintMsg, Msg: &PrintMsgReq{SN: "sn1", Content: "ticket"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the time before Run and a wake-up 3 seconds late both count, the job fires at 10 seconds
+	clock.Advance(4 * time.Second)
+	go func() {
+		_ = s.Run(ctx)
+	}()
+	for _, d := range []time.Duration{3 * time.Second, time.Second, time.Second} {
+		clock.BlockUntil(t, 1)
+		clock.Advance(d)
+	}
+	select {
+	case got := <-fired:
+		t.Fatalf("job fired early: %s", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.BlockUntil(t, 1)
+	clock.Advance(time.Second)
+	select {
+	case got := <-fired:
+		if got != "sn1_1" {
+			t.Errorf("fired = %s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the job to fire")
+	}
+}
@@ -0,0 +1,172 @@
+//go:build go1.21
+
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+// slog levels of the hlog levels slog does not define.
+const (
+	slogLevelTrace  = slog.LevelDebug - 4
+	slogLevelNotice = slog.LevelInfo + 2
+	slogLevelFatal  = slog.LevelError + 4
+)
+
+// slogLogger adapts a *slog.Logger to Logger and StructuredLogger.
+type slogLogger struct {
+	l *slog.Logger
+}
+
+var _ StructuredLogger = (*slogLogger)(nil)
+
+// NewSlogLogger returns a Logger writing to l, for Client.SetLogger.
+// Trace and Notice log at slog.LevelDebug-4 and slog.LevelInfo+2, Fatal logs at slog.LevelError+4
+// without exiting. SetLevel and SetOutput have no effect, the handler of l keeps its own.
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{l: l}
+}
+
+// WithSlogLogger sets a *slog.Logger as the logger, see NewSlogLogger.
+func WithSlogLogger(l *slog.Logger) Option {
+	return WithLogger(NewSlogLogger(l))
+}
+
+// slogLevel maps an hlog level to a slog level.
+func slogLevel(level hlog.Level) slog.Level {
+	switch level {
+	case hlog.LevelTrace:
+		return slogLevelTrace
+	case hlog.LevelDebug:
+		return slog.LevelDebug
+	case hlog.LevelInfo:
+		return slog.LevelInfo
+	case hlog.LevelNotice:
+		return slogLevelNotice
+	case hlog.LevelWarn:
+		return slog.LevelWarn
+	case hlog.LevelError:
+		return slog.LevelError
+	default:
+		return slogLevelFatal
+	}
+}
+
+func (s *slogLogger) log(ctx context.Context, level hlog.Level, v ...interface{}) {
+	if lvl := slogLevel(level); s.l.Enabled(ctx, lvl) {
+		s.l.Log(ctx, lvl, fmt.Sprint(v...))
+	}
+}
+
+func (s *slogLogger) logf(ctx context.Context, level hlog.Level, format string, v ...interface{}) {
+	if lvl := slogLevel(level); s.l.Enabled(ctx, lvl) {
+		s.l.Log(ctx, lvl, fmt.Sprintf(format, v...))
+	}
+}
+
+// CtxLogFields logs msg with fields as slog attributes.
+func (s *slogLogger) CtxLogFields(ctx context.Context, level Level, msg string, fields ...Field) {
+	lvl := slogLevel(hlog.Level(level))
+	if !s.l.Enabled(ctx, lvl) {
+		return
+	}
+	attrs := make([]slog.Attr, len(fields))
+	for i, f := range fields {
+		attrs[i] = slog.Any(f.Key, f.Value)
+	}
+	s.l.LogAttrs(ctx, lvl, msg, attrs...)
+}
+
+func (s *slogLogger) Trace(v ...interface{})  { s.log(context.Background(), hlog.LevelTrace, v...) }
+func (s *slogLogger) Debug(v ...interface{})  { s.log(context.Background(), hlog.LevelDebug, v...) }
+func (s *slogLogger) Info(v ...interface{})   { s.log(context.Background(), hlog.LevelInfo, v...) }
+func (s *slogLogger) Notice(v ...interface{}) { s.log(context.Background(), hlog.LevelNotice, v...) }
+func (s *slogLogger) Warn(v ...interface{})   { s.log(context.Background(), hlog.LevelWarn, v...) }
+func (s *slogLogger) Error(v ...interface{})  { s.log(context.Background(), hlog.LevelError, v...) }
+func (s *slogLogger) Fatal(v ...interface{})  { s.log(context.Background(), hlog.LevelFatal, v...) }
+
+func (s *slogLogger) Tracef(format string, v ...interface{}) {
+	s.logf(context.Background(), hlog.LevelTrace, format, v...)
+}
+
+func (s *slogLogger) Debugf(format string, v ...interface{}) {
+	s.logf(context.Background(), hlog.LevelDebug, format, v...)
+}
+
+func (s *slogLogger) Infof(format string, v ...interface{}) {
+	s.logf(context.Background(), hlog.LevelInfo, format, v...)
+}
+
+func (s *slogLogger) Noticef(format string, v ...interface{}) {
+	s.logf(context.Background(), hlog.LevelNotice, format, v...)
+}
+
+func (s *slogLogger) Warnf(format string, v ...interface{}) {
+	s.logf(context.Background(), hlog.LevelWarn, format, v...)
+}
+
+func (s *slogLogger) Errorf(format string, v ...interface{}) {
+	s.logf(context.Background(), hlog.LevelError, format, v...)
+}
+
+func (s *slogLogger) Fatalf(format string, v ...interface{}) {
+	s.logf(context.Background(), hlog.LevelFatal, format, v...)
+}
+
+func (s *slogLogger) CtxTracef(ctx context.Context, format string, v ...interface{}) {
+	s.logf(ctx, hlog.LevelTrace, format, v...)
+}
+
+func (s *slogLogger) CtxDebugf(ctx context.Context, format string, v ...interface{}) {
+	s.logf(ctx, hlog.LevelDebug, format, v...)
+}
+
+func (s *slogLogger) CtxInfof(ctx context.Context, format string, v ...interface{}) {
+	s.logf(ctx, hlog.LevelInfo, format, v...)
+}
+
+func (s *slogLogger) CtxNoticef(ctx context.Context, format string, v ...interface{}) {
+	s.logf(ctx, hlog.LevelNotice, format, v...)
+}
+
+func (s *slogLogger) CtxWarnf(ctx context.Context, format string, v ...interface{}) {
+	s.logf(ctx, hlog.LevelWarn, format, v...)
+}
+
+func (s *slogLogger) CtxErrorf(ctx context.Context, format string, v ...interface{}) {
+	s.logf(ctx, hlog.LevelError, format, v...)
+}
+
+func (s *slogLogger) CtxFatalf(ctx context.Context, format string, v ...interface{}) {
+	s.logf(ctx, hlog.LevelFatal, format, v...)
+}
+
+func (s *slogLogger) SetLevel(hlog.Level) {}
+
+func (s *slogLogger) SetOutput(io.Writer) {}
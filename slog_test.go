@@ -0,0 +1,73 @@
+//go:build go1.21
+
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestWithSlogLogger(t *testing.T) {
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": true}
+	})
+	var buf bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := New(context.Background(), WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL), WithSlogLogger(l))
+	if _, err := c.OpenQueryOrderState(context.Background(), &QueryOrderStateReq{OrderID: "sn_20240101120000_1"}); err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var r map[string]interface{}
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatal(err)
+		}
+		if r["msg"] == "feie request" {
+			record = r
+		}
+	}
+	if record == nil {
+		t.Fatalf("no request record in %s", buf.String())
+	}
+	for k, want := range map[string]interface{}{"level": "DEBUG", "api": queryOrderState, "order_id": "sn_20240101120000_1", "ret": float64(0)} {
+		if record[k] != want {
+			t.Errorf("%s = %#v, want %#v", k, record[k], want)
+		}
+	}
+	if _, ok := record["latency"].(float64); !ok {
+		t.Errorf("latency = %#v, want a number", record["latency"])
+	}
+}
+
+func TestSlogLogger_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	logger.CtxDebugf(context.Background(), "debug")
+	logger.Notice("notice")
+	logger.CtxErrorf(context.Background(), "error %d", 1)
+	if got := buf.String(); bytes.Contains(buf.Bytes(), []byte("debug")) || bytes.Contains(buf.Bytes(), []byte("notice")) || !bytes.Contains(buf.Bytes(), []byte(`msg="error 1"`)) {
+		t.Errorf("output = %q, want only the error", got)
+	}
+}
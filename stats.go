@@ -0,0 +1,221 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// dateLayout is the date format of Open_queryOrderInfoByDate.
+const dateLayout = "2006-01-02"
+
+// Shanghai is the timezone feieyun dates and order IDs are expressed in.
+var Shanghai = loadShanghai()
+
+// loadShanghai loads Asia/Shanghai, falling back to UTC+8 when the tz database is missing.
+func loadShanghai() *time.Location {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return time.FixedZone("CST", 8*60*60)
+	}
+	return loc
+}
+
+// FormatDate formats t as the date field of Open_queryOrderInfoByDate in Asia/Shanghai.
+func FormatDate(t time.Time) string {
+	return t.In(Shanghai).Format(dateLayout)
+}
+
+// DailyStats is the order count of one printer on one day.
+type DailyStats struct {
+	SN      string `json:"sn"`
+	Date    string `json:"date"`
+	Printed int    `json:"printed"`
+	Waiting int    `json:"waiting"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PrinterStats is the order counts of one printer over the date range.
+type PrinterStats struct {
+	SN      string        `json:"sn"`
+	Days    []*DailyStats `json:"days"`
+	Printed int           `json:"printed"`
+	Waiting int           `json:"waiting"`
+}
+
+// StatsReport is the result of QueryStats.
+type StatsReport struct {
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Printers []*PrinterStats `json:"printers"`
+	Printed  int             `json:"printed"`
+	Waiting  int             `json:"waiting"`
+}
+
+// StatsOption is the option of QueryStats.
+type StatsOption func(o *statsOptions)
+
+// statsOptions is the options of QueryStats.
+type statsOptions struct {
+	concurrency int
+	maxDays     int
+}
+
+// WithStatsConcurrency sets the number of queries run concurrently, default 4.
+func WithStatsConcurrency(n int) StatsOption {
+	return func(o *statsOptions) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// WithStatsMaxDays sets the longest date range accepted, default 366 days.
+func WithStatsMaxDays(n int) StatsOption {
+	return func(o *statsOptions) {
+		if n > 0 {
+			o.maxDays = n
+		}
+	}
+}
+
+// QueryStats queries Open_queryOrderInfoByDate for every printer and every day from from to to,
+// both included, as dates in Asia/Shanghai. The days that fail keep their error in the report
+// and are reported in a *BatchError, the totals only count the days that succeeded.
+func (c *Client) QueryStats(ctx context.Context, sns []string, from, to time.Time, opts ...StatsOption) (*StatsReport, error) {
+	op := statsOptions{concurrency: 4, maxDays: 366}
+	for _, opt := range opts {
+		opt(&op)
+	}
+	if len(sns) == 0 {
+		return nil, ErrEmptyPrinters
+	}
+	from, to = from.In(Shanghai), to.In(Shanghai)
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, Shanghai)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, Shanghai)
+	if end.Before(start) {
+		return nil, errors.New("feie: stats range ends before it starts")
+	}
+	var dates []string
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if len(dates) == op.maxDays {
+			return nil, fmt.Errorf("feie: stats range exceeds %d days", op.maxDays)
+		}
+		dates = append(dates, day.Format(dateLayout))
+	}
+
+	var (
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, op.concurrency)
+		report = &StatsReport{From: dates[0], To: dates[len(dates)-1], Printers: make([]*PrinterStats, len(sns))}
+	)
+	for i, sn := range sns {
+		printer := &PrinterStats{SN: sn, Days: make([]*DailyStats, len(dates))}
+		report.Printers[i] = printer
+		for j, date := range dates {
+			day := &DailyStats{SN: sn, Date: date}
+			printer.Days[j] = day
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(day *DailyStats) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				if err := c.queryDailyStats(ctx, day); err != nil {
+					day.Error = err.Error()
+				}
+			}(day)
+		}
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, printer := range report.Printers {
+		for _, day := range printer.Days {
+			if day.Error != "" {
+				errs = append(errs, fmt.Errorf("printer %s on %s: %s", day.SN, day.Date, day.Error))
+				continue
+			}
+			printer.Printed += day.Printed
+			printer.Waiting += day.Waiting
+		}
+		report.Printed += printer.Printed
+		report.Waiting += printer.Waiting
+	}
+	if len(errs) > 0 {
+		return report, &BatchError{Errors: errs}
+	}
+	return report, nil
+}
+
+// queryDailyStats fills the counts of day.
+func (c *Client) queryDailyStats(ctx context.Context, day *DailyStats) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	resp, err := c.OpenQueryOrderInfoByDate(ctx, &QueryOrderInfoByDateReq{SN: day.SN, Date: day.Date})
+	if err != nil {
+		return err
+	}
+	if resp.Ret != 0 {
+		return &APIError{API: queryOrderInfoByDate, Ret: resp.Ret, Msg: resp.Msg}
+	}
+	if resp.Data != nil {
+		day.Printed, day.Waiting = resp.Data.Print, resp.Data.Waiting
+	}
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *StatsReport) WriteJSON(w io.Writer) error {
+	content, err := sonic.ConfigStd.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(content, '\n'))
+	return err
+}
+
+// WriteCSV writes one row per printer and day, followed by a total row per printer
+// with the date "total" and a grand total row with the sn "total".
+func (r *StatsReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{{"sn", "date", "printed", "waiting", "error"}}
+	for _, printer := range r.Printers {
+		for _, day := range printer.Days {
+			rows = append(rows, []string{day.SN, day.Date, strconv.Itoa(day.Printed), strconv.Itoa(day.Waiting), day.Error})
+		}
+		rows = append(rows, []string{printer.SN, "total", strconv.Itoa(printer.Printed), strconv.Itoa(printer.Waiting), ""})
+	}
+	rows = append(rows, []string{"total", r.From + "~" + r.To, strconv.Itoa(r.Printed), strconv.Itoa(r.Waiting), ""})
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
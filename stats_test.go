@@ -0,0 +1,80 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_QueryStats(t *testing.T) {
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		if form[SNField] == "sn2" && form[DateField] == "2024-01-03" {
+			return map[string]interface{}{"ret": 1002, "msg": "打印机编号错误"}
+		}
+		day := int(form[DateField][len(form[DateField])-1] - '0')
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": map[string]int{"print": day, "waiting": 1}}
+	})
+	var (
+		ctx  = context.Background()
+		c    = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		from = time.Date(2024, 1, 1, 17, 0, 0, 0, time.UTC)
+		to   = time.Date(2024, 1, 3, 16, 30, 0, 0, time.UTC)
+	)
+	report, err := c.QueryStats(ctx, []string{"sn1", "sn2"}, from, to, WithStatsConcurrency(3))
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 1 {
+		t.Fatalf("QueryStats() error = %v, want one failed day", err)
+	}
+	if report.From != "2024-01-02" || report.To != "2024-01-04" {
+		t.Errorf("QueryStats() range = %s~%s, want 2024-01-02~2024-01-04 in Asia/Shanghai", report.From, report.To)
+	}
+	if report.Printers[0].Printed != 9 || report.Printers[0].Waiting != 3 || report.Printers[1].Printed != 6 || report.Printed != 15 {
+		t.Errorf("QueryStats() totals = %+v %+v %d", report.Printers[0], report.Printers[1], report.Printed)
+	}
+
+	var buf bytes.Buffer
+	if err = report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"sn,date,printed,waiting,error",
+		"sn1,2024-01-02,2,1,",
+		"sn1,2024-01-03,3,1,",
+		"sn1,2024-01-04,4,1,",
+		"sn1,total,9,3,",
+		"sn2,2024-01-02,2,1,",
+		"sn2,2024-01-03,0,0,feie: Open_queryOrderInfoByDate ret 1002: 打印机编号错误",
+		"sn2,2024-01-04,4,1,",
+		"sn2,total,6,2,",
+		"total,2024-01-02~2024-01-04,15,5,",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("WriteCSV() got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err = c.QueryStats(ctx, []string{"sn1"}, to, from); err == nil {
+		t.Errorf("QueryStats() with reversed range want error")
+	}
+}
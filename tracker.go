@@ -0,0 +1,474 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+var (
+	// ErrOrderNotFound is returned when the order is not tracked.
+	ErrOrderNotFound = errors.New("feie: order not found")
+
+	// ErrInvalidSign is returned when a callback fails the signature verification.
+	ErrInvalidSign = errors.New("feie: invalid callback sign")
+)
+
+// OrderState is the print state of a tracked order.
+type OrderState string
+
+const (
+	// OrderPending the order is waiting to be printed.
+	OrderPending OrderState = "pending"
+	// OrderPrinted the order is printed.
+	OrderPrinted OrderState = "printed"
+	// OrderFailed a callback reported the order failed to print.
+	OrderFailed OrderState = "failed"
+	// OrderTimedOut the order was not printed before it expired.
+	OrderTimedOut OrderState = "timed_out"
+)
+
+// Done reports whether the state is final.
+func (s OrderState) Done() bool {
+	return s != OrderPending
+}
+
+// TrackedOrder is an order returned by Open_printMsg or Open_printLabelMsg followed by Tracker.
+type TrackedOrder struct {
+	OrderID   string     `json:"orderId"`
+	SN        string     `json:"sn"`
+	State     OrderState `json:"state"`
+	Expired   time.Time  `json:"expired"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	Attempts  int        `json:"attempts"`
+	NextCheck time.Time  `json:"nextCheck"`
+}
+
+// OrderStore persists the orders of Tracker.
+type OrderStore interface {
+	// Save creates or replaces the order.
+	Save(ctx context.Context, order *TrackedOrder) error
+	// Get returns the order, ErrOrderNotFound if it does not exist.
+	Get(ctx context.Context, orderID string) (*TrackedOrder, error)
+	// Delete removes the order.
+	Delete(ctx context.Context, orderID string) error
+	// List returns all orders.
+	List(ctx context.Context) ([]*TrackedOrder, error)
+}
+
+// MemoryOrderStore is an OrderStore kept in memory.
+type MemoryOrderStore struct {
+	mu     sync.RWMutex
+	orders map[string]TrackedOrder
+}
+
+// NewMemoryOrderStore returns a new MemoryOrderStore.
+func NewMemoryOrderStore() *MemoryOrderStore {
+	return &MemoryOrderStore{orders: make(map[string]TrackedOrder)}
+}
+
+// Save creates or replaces the order.
+func (s *MemoryOrderStore) Save(_ context.Context, order *TrackedOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[order.OrderID] = *order
+	return nil
+}
+
+// Get returns the order, ErrOrderNotFound if it does not exist.
+func (s *MemoryOrderStore) Get(_ context.Context, orderID string) (*TrackedOrder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	order, ok := s.orders[orderID]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	return &order, nil
+}
+
+// Delete removes the order.
+func (s *MemoryOrderStore) Delete(_ context.Context, orderID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.orders, orderID)
+	return nil
+}
+
+// List returns all orders sorted by creation time.
+func (s *MemoryOrderStore) List(_ context.Context) ([]*TrackedOrder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	orders := make([]*TrackedOrder, 0, len(s.orders))
+	for _, order := range s.orders {
+		order := order
+		orders = append(orders, &order)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].OrderID < orders[j].OrderID
+		}
+		return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+	})
+	return orders, nil
+}
+
+// FileOrderStore is an OrderStore kept in memory and written to a JSON file on every change.
+type FileOrderStore struct {
+	path   string
+	memory *MemoryOrderStore
+	mu     sync.Mutex
+}
+
+// NewFileOrderStore returns a new FileOrderStore loading the orders from path if it exists.
+func NewFileOrderStore(path string) (*FileOrderStore, error) {
+	s := &FileOrderStore{path: path, memory: NewMemoryOrderStore()}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = sonic.Unmarshal(content, &s.memory.orders); err != nil {
+		return nil, err
+	}
+	if s.memory.orders == nil {
+		s.memory.orders = make(map[string]TrackedOrder)
+	}
+	return s, nil
+}
+
+// Save creates or replaces the order.
+func (s *FileOrderStore) Save(ctx context.Context, order *TrackedOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.memory.Save(ctx, order)
+	return s.flush()
+}
+
+// Get returns the order, ErrOrderNotFound if it does not exist.
+func (s *FileOrderStore) Get(ctx context.Context, orderID string) (*TrackedOrder, error) {
+	return s.memory.Get(ctx, orderID)
+}
+
+// Delete removes the order.
+func (s *FileOrderStore) Delete(ctx context.Context, orderID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.memory.Delete(ctx, orderID)
+	return s.flush()
+}
+
+// List returns all orders sorted by creation time.
+func (s *FileOrderStore) List(ctx context.Context) ([]*TrackedOrder, error) {
+	return s.memory.List(ctx)
+}
+
+// flush writes all orders to the file.
+func (s *FileOrderStore) flush() error {
+	s.memory.mu.RLock()
+	content, err := sonic.ConfigStd.Marshal(s.memory.orders)
+	s.memory.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, content)
+}
+
+// TrackerOption is the option of Tracker.
+type TrackerOption func(t *Tracker)
+
+// WithTrackerClock sets the clock, default the system clock.
+func WithTrackerClock(clock Clock) TrackerOption {
+	return func(t *Tracker) {
+		t.clock = clock
+	}
+}
+
+// WithTrackerInterval sets how often Run looks for orders due to be checked, default 5 seconds.
+func WithTrackerInterval(d time.Duration) TrackerOption {
+	return func(t *Tracker) {
+		if d > 0 {
+			t.interval = d
+		}
+	}
+}
+
+// WithTrackerBackoff sets the first and the maximum delay between two Open_queryOrderState calls
+// of an order, default 10 seconds and 5 minutes. The delay doubles after every check.
+func WithTrackerBackoff(min, max time.Duration) TrackerOption {
+	return func(t *Tracker) {
+		if min > 0 && max >= min {
+			t.minBackoff, t.maxBackoff = min, max
+		}
+	}
+}
+
+// WithTrackerTTL sets the expiry of orders submitted without expired, default 24 hours.
+func WithTrackerTTL(d time.Duration) TrackerOption {
+	return func(t *Tracker) {
+		if d > 0 {
+			t.ttl = d
+		}
+	}
+}
+
+// WithTrackerRetention sets how long finished orders are kept in the store, default 24 hours.
+func WithTrackerRetention(d time.Duration) TrackerOption {
+	return func(t *Tracker) {
+		if d > 0 {
+			t.retention = d
+		}
+	}
+}
+
+// WithTrackerCallback calls fn every time an order reaches a final state.
+func WithTrackerCallback(fn func(ctx context.Context, order *TrackedOrder)) TrackerOption {
+	return func(t *Tracker) {
+		t.callback = fn
+	}
+}
+
+// Tracker follows the print state of orders. Orders are marked printed by verified callbacks,
+// orders that stay pending are checked with Open_queryOrderState with backoff, and orders
+// past their expiry are marked timed out.
+type Tracker struct {
+	client     *Client
+	store      OrderStore
+	clock      Clock
+	interval   time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	ttl        time.Duration
+	retention  time.Duration
+	callback   func(ctx context.Context, order *TrackedOrder)
+	mu         sync.Mutex
+}
+
+// NewTracker returns a new Tracker, a nil store is a MemoryOrderStore.
+func NewTracker(c *Client, store OrderStore, opts ...TrackerOption) *Tracker {
+	if store == nil {
+		store = NewMemoryOrderStore()
+	}
+	t := &Tracker{
+		client:     c,
+		store:      store,
+		clock:      systemClock{},
+		interval:   5 * time.Second,
+		minBackoff: 10 * time.Second,
+		maxBackoff: 5 * time.Minute,
+		ttl:        24 * time.Hour,
+		retention:  24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// PrintMsg calls OpenPrintMsg and tracks the returned order.
+func (t *Tracker) PrintMsg(ctx context.Context, req *PrintMsgReq) (*PrintMsgResp, error) {
+	resp, err := t.client.OpenPrintMsg(ctx, req)
+	if err != nil || resp.Ret != 0 {
+		return resp, err
+	}
+	return resp, t.Track(ctx, resp.Data, req.SN, req.Expired)
+}
+
+// PrintLabelMsg calls OpenPrintLabelMsg and tracks the returned order.
+func (t *Tracker) PrintLabelMsg(ctx context.Context, req *PrintLabelMsgReq) (*PrintLabelMsgResp, error) {
+	resp, err := t.client.OpenPrintLabelMsg(ctx, req)
+	if err != nil || resp.Ret != 0 {
+		return resp, err
+	}
+	return resp, t.Track(ctx, resp.Data, req.SN, req.Expired)
+}
+
+// Track starts following the order, expired is the UNIX timestamp the order expires at,
+// 0 means the TTL set by WithTrackerTTL. An empty sn is taken from the order ID.
+func (t *Tracker) Track(ctx context.Context, orderID, sn string, expired int64) error {
+	if sn == "" {
+		sn = OrderID(orderID).SN()
+	}
+	now := t.clock.Now()
+	order := &TrackedOrder{
+		OrderID:   orderID,
+		SN:        sn,
+		State:     OrderPending,
+		Expired:   now.Add(t.ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+		NextCheck: now.Add(t.minBackoff),
+	}
+	if expired > 0 {
+		order.Expired = time.Unix(expired, 0)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.store.Save(ctx, order)
+}
+
+// Get returns the tracked order.
+func (t *Tracker) Get(ctx context.Context, orderID string) (*TrackedOrder, error) {
+	return t.store.Get(ctx, orderID)
+}
+
+// HandleCallback updates the order from a callback verified by Client.AsyncPrinterResult.
+// Status 1 marks the order printed, any other status marks it failed.
+func (t *Tracker) HandleCallback(ctx context.Context, result *AsyncPrinterResultResp) error {
+	if result == nil || !result.VerifySign {
+		return ErrInvalidSign
+	}
+	state := OrderFailed
+	if result.Status == 1 {
+		state = OrderPrinted
+	}
+	_, err := t.update(ctx, result.OrderID, func(order *TrackedOrder) bool {
+		order.State = state
+		return true
+	})
+	return err
+}
+
+// Run checks the pending orders until ctx is done and returns ctx.Err().
+func (t *Tracker) Run(ctx context.Context) error {
+	for {
+		if err := t.Check(ctx); err != nil && ctx.Err() == nil {
+			t.client.logEvent(ctx, hlog.LevelWarn, "feie tracker check failed", Field{Key: "error", Value: err})
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.clock.After(t.interval):
+		}
+	}
+}
+
+// Check runs one round: orders due are queried, expired orders are queried a last time and
+// timed out when not printed, and finished orders past the retention are deleted. An order
+// failing does not stop the round, the returned error is a *BatchError of the failed orders.
+func (t *Tracker) Check(ctx context.Context) error {
+	orders, err := t.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	var (
+		now  = t.clock.Now()
+		errs []error
+	)
+	for _, order := range orders {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err = nil
+		switch {
+		case order.State.Done():
+			if now.Sub(order.UpdatedAt) > t.retention {
+				t.mu.Lock()
+				err = t.store.Delete(ctx, order.OrderID)
+				t.mu.Unlock()
+			}
+		case !now.Before(order.Expired):
+			err = t.query(ctx, order.OrderID, true)
+		case !now.Before(order.NextCheck):
+			err = t.query(ctx, order.OrderID, false)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("feie: tracker order %s: %w", order.OrderID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return &BatchError{Errors: errs}
+	}
+	return nil
+}
+
+// query checks the order with Open_queryOrderState and schedules the next check. An expired
+// order that feieyun answers is not printed is timed out, one whose query failed before an
+// answer is queried again on the next round.
+func (t *Tracker) query(ctx context.Context, orderID string, expired bool) error {
+	resp, err := t.client.OpenQueryOrderState(ctx, &QueryOrderStateReq{OrderID: orderID})
+	if err == nil && resp.Ret != 0 {
+		err = &APIError{API: queryOrderState, Ret: resp.Ret, Msg: resp.Msg}
+	}
+	if err != nil {
+		t.client.logEvent(ctx, hlog.LevelWarn, "feie tracker query order failed",
+			Field{Key: "order_id", Value: orderID}, Field{Key: "error", Value: err})
+	}
+	_, uerr := t.update(ctx, orderID, func(order *TrackedOrder) bool {
+		var apiErr *APIError
+		switch {
+		case err == nil && resp.Data:
+			order.State = OrderPrinted
+			return true
+		case expired && (err == nil || errors.As(err, &apiErr)):
+			order.State = OrderTimedOut
+			return true
+		}
+		order.Attempts++
+		order.NextCheck = t.clock.Now().Add(t.backoff(order.Attempts))
+		return false
+	})
+	return uerr
+}
+
+// backoff returns the delay before the next check after attempts checks.
+func (t *Tracker) backoff(attempts int) time.Duration {
+	d := t.minBackoff
+	for i := 1; i < attempts && d < t.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > t.maxBackoff {
+		d = t.maxBackoff
+	}
+	return d
+}
+
+// update applies fn to the pending order and saves it, fn returns whether the order reached
+// a final state. Orders already finished are left untouched.
+func (t *Tracker) update(ctx context.Context, orderID string, fn func(order *TrackedOrder) bool) (*TrackedOrder, error) {
+	t.mu.Lock()
+	order, err := t.store.Get(ctx, orderID)
+	if err != nil {
+		t.mu.Unlock()
+		return nil, err
+	}
+	if order.State.Done() {
+		t.mu.Unlock()
+		return order, nil
+	}
+	done := fn(order)
+	order.UpdatedAt = t.clock.Now()
+	err = t.store.Save(ctx, order)
+	t.mu.Unlock()
+	if err == nil && done && t.callback != nil {
+		t.callback(ctx, order)
+	}
+	return order, err
+}
@@ -0,0 +1,185 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTracker_Check(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		printed = map[string]bool{}
+		queries int
+	)
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		queries++
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": printed[form[OrderIDField]]}
+	})
+	var (
+		ctx   = context.Background()
+		clock = newFakeClock()
+		c     = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		store = NewMemoryOrderStore()
+		done  []string
+		tr    = NewTracker(c, store, WithTrackerClock(clock), WithTrackerBackoff(10*time.Second, time.Minute),
+			WithTrackerCallback(func(ctx context.Context, order *TrackedOrder) {
+				done = append(done, order.OrderID+" "+string(order.State))
+			}))
+	)
+	for _, id := range []string{"a", "b"} {
+		if err := tr.Track(ctx, id, "sn1", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.Track(ctx, "c", "sn1", clock.Now().Add(time.Minute).Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.HandleCallback(ctx, &AsyncPrinterResultResp{OrderID: "a", Status: 1}); err != ErrInvalidSign {
+		t.Errorf("HandleCallback() unverified error = %v, want %v", err, ErrInvalidSign)
+	}
+	if err := tr.HandleCallback(ctx, &AsyncPrinterResultResp{VerifySign: true, OrderID: "a", Status: 1}); err != nil {
+		t.Fatalf("HandleCallback() error = %v", err)
+	}
+	if err := tr.HandleCallback(ctx, &AsyncPrinterResultResp{VerifySign: true, OrderID: "x", Status: 1}); err != ErrOrderNotFound {
+		t.Errorf("HandleCallback() unknown order error = %v, want %v", err, ErrOrderNotFound)
+	}
+
+	if err := tr.Check(ctx); err != nil || queries != 0 {
+		t.Fatalf("Check() before backoff error = %v, queries = %d", err, queries)
+	}
+	clock.Advance(10 * time.Second)
+	if err := tr.Check(ctx); err != nil || queries != 2 {
+		t.Fatalf("Check() error = %v, queries = %d, want 2", err, queries)
+	}
+	mu.Lock()
+	printed["b"] = true
+	mu.Unlock()
+	clock.Advance(10 * time.Second)
+	if err := tr.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if err := tr.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]OrderState{"a": OrderPrinted, "b": OrderPrinted, "c": OrderTimedOut}
+	for id, state := range want {
+		order, err := tr.Get(ctx, id)
+		if err != nil || order.State != state {
+			t.Errorf("Get(%s) = %+v, %v, want state %s", id, order, err, state)
+		}
+	}
+	if len(done) != 3 {
+		t.Errorf("callback calls = %v, want 3", done)
+	}
+
+	clock.Advance(25 * time.Hour)
+	if err := tr.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if orders, _ := store.List(ctx); len(orders) != 0 {
+		t.Errorf("List() after retention = %d orders, want 0", len(orders))
+	}
+}
+
+func TestFileOrderStore(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		path  = filepath.Join(t.TempDir(), "orders.json")
+		order = &TrackedOrder{OrderID: "816501678_20160919184316_1419533539", SN: "816501678", State: OrderPending}
+	)
+	store, err := NewFileOrderStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Save(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	if store, err = NewFileOrderStore(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(ctx, order.OrderID)
+	if err != nil || got.SN != order.SN || got.State != order.State {
+		t.Errorf("Get() = %+v, %v, want %+v", got, err, order)
+	}
+	if err = store.Delete(ctx, order.OrderID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Get(ctx, order.OrderID); err != ErrOrderNotFound {
+		t.Errorf("Get() after Delete error = %v, want %v", err, ErrOrderNotFound)
+	}
+}
+
+// failingOrderStore fails saving the orders in fail.
+type failingOrderStore struct {
+	*MemoryOrderStore
+	fail map[string]bool
+}
+
+// Save fails for the orders in fail.
+func (s *failingOrderStore) Save(ctx context.Context, order *TrackedOrder) error {
+	if s.fail[order.OrderID] {
+		return errors.New("disk full")
+	}
+	return s.MemoryOrderStore.Save(ctx, order)
+}
+
+func TestTracker_CheckExpired(t *testing.T) {
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		return map[string]interface{}{"ret": 0, "msg": "ok", "data": form[OrderIDField] == "late"}
+	})
+	var (
+		ctx     = context.Background()
+		clock   = newFakeClock()
+		c       = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL))
+		store   = &failingOrderStore{MemoryOrderStore: NewMemoryOrderStore(), fail: map[string]bool{}}
+		tr      = NewTracker(c, store, WithTrackerClock(clock), WithTrackerBackoff(time.Hour, time.Hour))
+		expired = clock.Now().Add(time.Minute).Unix()
+	)
+	for _, id := range []string{"late", "lost", "broken"} {
+		if err := tr.Track(ctx, id, "sn1", expired); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.fail["broken"] = true
+	clock.Advance(time.Minute)
+	err := tr.Check(ctx)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 1 {
+		t.Fatalf("Check() error = %v, want the broken order only", err)
+	}
+	// an order printed just before it expired is not timed out
+	want := map[string]OrderState{"late": OrderPrinted, "lost": OrderTimedOut, "broken": OrderPending}
+	for id, state := range want {
+		order, err := tr.Get(ctx, id)
+		if err != nil || order.State != state {
+			t.Errorf("Get(%s) = %+v, %v, want state %s", id, order, err, state)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// Transport sends the signed form of an API call to the gateway and returns the response body.
+// The form already carries user, stime and sig, see WithTransport.
+type Transport interface {
+	Do(ctx context.Context, gateway string, form map[string]string) ([]byte, error)
+}
+
+// TransportFunc adapts a func to Transport.
+type TransportFunc func(ctx context.Context, gateway string, form map[string]string) ([]byte, error)
+
+// Do calls f.
+func (f TransportFunc) Do(ctx context.Context, gateway string, form map[string]string) ([]byte, error) {
+	return f(ctx, gateway, form)
+}
+
+// HTTPTransport posts the form as multipart/form-data with the hertz client, the same as the
+// client does without WithTransport. It is safe for concurrent use.
+type HTTPTransport struct {
+	timeout   time.Duration
+	userAgent []byte
+	once      sync.Once
+	hc        *client.Client
+	err       error
+}
+
+// NewHTTPTransport returns an HTTPTransport with the dial timeout, 0 means 30 seconds.
+func NewHTTPTransport(timeout time.Duration) *HTTPTransport {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &HTTPTransport{timeout: timeout, userAgent: userAgent}
+}
+
+// Do implements Transport.
+func (t *HTTPTransport) Do(ctx context.Context, gateway string, form map[string]string) ([]byte, error) {
+	t.once.Do(func() {
+		t.hc, t.err = newHTTPClient(t.timeout)
+	})
+	if t.err != nil {
+		return nil, t.err
+	}
+	response, err := postForm(ctx, t.hc, &protocol.Request{}, gateway, t.userAgent, form)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body(), nil
+}
+
+// newHTTPClient returns the hertz client used to reach the gateway.
+func newHTTPClient(timeout time.Duration) (*client.Client, error) {
+	return client.NewClient(client.WithTLSConfig(&tls.Config{
+		InsecureSkipVerify: true,
+	}), client.WithDialTimeout(timeout))
+}
+
+// postForm posts the form with the headers already set on request and returns the response.
+func postForm(ctx context.Context, hc *client.Client, request *protocol.Request, gateway string, ua []byte, form map[string]string) (*protocol.Response, error) {
+	response := &protocol.Response{}
+	request.SetMultipartFormData(form)
+	request.SetRequestURI(gateway)
+	request.Header.SetMethod(consts.MethodPost)
+	request.Header.SetUserAgentBytes(ua)
+	if err := hc.Do(ctx, request, response); err != nil {
+		return nil, err
+	}
+	if response.StatusCode() == consts.StatusServiceUnavailable {
+		return nil, &notSentError{err: errors.New("feie: gateway unavailable: 503 Service Unavailable")}
+	}
+	if !response.HasBodyBytes() {
+		return nil, errors.New("response is empty")
+	}
+	return response, nil
+}
+
+// notSentError is an error returned before the request reached feieyun, so sending it again
+// cannot print the ticket twice.
+type notSentError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *notSentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *notSentError) Unwrap() error {
+	return e.err
+}
+
+// requestNotSent reports whether err proves the request never reached feieyun: it failed
+// before the transport was called, the connection could not be dialed, or the gateway answered 503.
+func requestNotSent(err error) bool {
+	var ns *notSentError
+	if errors.As(err, &ns) {
+		return true
+	}
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
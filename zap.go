@@ -0,0 +1,138 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// zapLogger adapts a *zap.Logger to Logger and StructuredLogger.
+type zapLogger struct {
+	l *zap.Logger
+}
+
+var _ StructuredLogger = (*zapLogger)(nil)
+
+// NewZapLogger returns a Logger writing to l, for Client.SetLogger.
+// Trace logs at debug level, Notice at info level, and Fatal at error level without exiting.
+// SetLevel and SetOutput have no effect, l keeps its own level and output.
+func NewZapLogger(l *zap.Logger) Logger {
+	if l == nil {
+		l = zap.NewNop()
+	}
+	return &zapLogger{l: l.WithOptions(zap.AddCallerSkip(1))}
+}
+
+// WithZapLogger sets a *zap.Logger as the logger, see NewZapLogger.
+func WithZapLogger(l *zap.Logger) Option {
+	return WithLogger(NewZapLogger(l))
+}
+
+// zapLevel maps an hlog level to a zap level.
+func zapLevel(level hlog.Level) zapcore.Level {
+	switch level {
+	case hlog.LevelTrace, hlog.LevelDebug:
+		return zapcore.DebugLevel
+	case hlog.LevelInfo, hlog.LevelNotice:
+		return zapcore.InfoLevel
+	case hlog.LevelWarn:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+func (z *zapLogger) log(level hlog.Level, v ...interface{}) {
+	if lvl := zapLevel(level); z.l.Core().Enabled(lvl) {
+		z.l.Log(lvl, fmt.Sprint(v...))
+	}
+}
+
+func (z *zapLogger) logf(level hlog.Level, format string, v ...interface{}) {
+	if lvl := zapLevel(level); z.l.Core().Enabled(lvl) {
+		z.l.Log(lvl, fmt.Sprintf(format, v...))
+	}
+}
+
+// CtxLogFields logs msg with fields as zap fields.
+func (z *zapLogger) CtxLogFields(_ context.Context, level Level, msg string, fields ...Field) {
+	lvl := zapLevel(hlog.Level(level))
+	if !z.l.Core().Enabled(lvl) {
+		return
+	}
+	zf := make([]zap.Field, len(fields))
+	for i, f := range fields {
+		zf[i] = zap.Any(f.Key, f.Value)
+	}
+	z.l.Log(lvl, msg, zf...)
+}
+
+func (z *zapLogger) Trace(v ...interface{})  { z.log(hlog.LevelTrace, v...) }
+func (z *zapLogger) Debug(v ...interface{})  { z.log(hlog.LevelDebug, v...) }
+func (z *zapLogger) Info(v ...interface{})   { z.log(hlog.LevelInfo, v...) }
+func (z *zapLogger) Notice(v ...interface{}) { z.log(hlog.LevelNotice, v...) }
+func (z *zapLogger) Warn(v ...interface{})   { z.log(hlog.LevelWarn, v...) }
+func (z *zapLogger) Error(v ...interface{})  { z.log(hlog.LevelError, v...) }
+func (z *zapLogger) Fatal(v ...interface{})  { z.log(hlog.LevelFatal, v...) }
+
+func (z *zapLogger) Tracef(format string, v ...interface{})  { z.logf(hlog.LevelTrace, format, v...) }
+func (z *zapLogger) Debugf(format string, v ...interface{})  { z.logf(hlog.LevelDebug, format, v...) }
+func (z *zapLogger) Infof(format string, v ...interface{})   { z.logf(hlog.LevelInfo, format, v...) }
+func (z *zapLogger) Noticef(format string, v ...interface{}) { z.logf(hlog.LevelNotice, format, v...) }
+func (z *zapLogger) Warnf(format string, v ...interface{})   { z.logf(hlog.LevelWarn, format, v...) }
+func (z *zapLogger) Errorf(format string, v ...interface{})  { z.logf(hlog.LevelError, format, v...) }
+func (z *zapLogger) Fatalf(format string, v ...interface{})  { z.logf(hlog.LevelFatal, format, v...) }
+
+func (z *zapLogger) CtxTracef(_ context.Context, format string, v ...interface{}) {
+	z.logf(hlog.LevelTrace, format, v...)
+}
+
+func (z *zapLogger) CtxDebugf(_ context.Context, format string, v ...interface{}) {
+	z.logf(hlog.LevelDebug, format, v...)
+}
+
+func (z *zapLogger) CtxInfof(_ context.Context, format string, v ...interface{}) {
+	z.logf(hlog.LevelInfo, format, v...)
+}
+
+func (z *zapLogger) CtxNoticef(_ context.Context, format string, v ...interface{}) {
+	z.logf(hlog.LevelNotice, format, v...)
+}
+
+func (z *zapLogger) CtxWarnf(_ context.Context, format string, v ...interface{}) {
+	z.logf(hlog.LevelWarn, format, v...)
+}
+
+func (z *zapLogger) CtxErrorf(_ context.Context, format string, v ...interface{}) {
+	z.logf(hlog.LevelError, format, v...)
+}
+
+func (z *zapLogger) CtxFatalf(_ context.Context, format string, v ...interface{}) {
+	z.logf(hlog.LevelFatal, format, v...)
+}
+
+func (z *zapLogger) SetLevel(hlog.Level) {}
+
+func (z *zapLogger) SetOutput(io.Writer) {}
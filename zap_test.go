@@ -0,0 +1,142 @@
+/*
+ *  Copyright `FeiE` Author(https://houseme.github.io/feie/). All Rights Reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ *  You can obtain one at https://github.com/houseme/feie.
+ */
+
+package feie
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithZapLogger(t *testing.T) {
+	tests := []struct {
+		name   string
+		resp   map[string]interface{}
+		level  zapcore.Level
+		msg    string
+		fields map[string]interface{}
+	}{
+		{
+			name:   "printed",
+			resp:   map[string]interface{}{"ret": 0, "msg": "ok", "data": "sn_20240101120000_1"},
+			level:  zapcore.DebugLevel,
+			msg:    "feie request",
+			fields: map[string]interface{}{"api": printMsg, "sn": "sn", "order_id": "sn_20240101120000_1", "ret": int64(0)},
+		},
+		{
+			name:   "ret",
+			resp:   map[string]interface{}{"ret": 1002, "msg": "打印机编号错误"},
+			level:  zapcore.WarnLevel,
+			msg:    "feie request returned an error",
+			fields: map[string]interface{}{"api": printMsg, "sn": "sn", "ret": int64(1002), "msg": "打印机编号错误"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestGateway(t, func(form map[string]string) interface{} { return tt.resp })
+			core, logs := observer.New(zapcore.DebugLevel)
+			c := New(context.Background(), WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL), WithZapLogger(zap.New(core)))
+			if _, err := c.OpenPrintMsg(context.Background(), &PrintMsgReq{SN: "sn", Content: "test"}); err != nil {
+				t.Fatal(err)
+			}
+			entries := logs.FilterMessage(tt.msg).All()
+			if len(entries) != 1 {
+				t.Fatalf("got %d %q entries, want 1: %v", len(entries), tt.msg, logs.All())
+			}
+			if entries[0].Level != tt.level {
+				t.Errorf("level = %v, want %v", entries[0].Level, tt.level)
+			}
+			got := entries[0].ContextMap()
+			for k, want := range tt.fields {
+				if got[k] != want {
+					t.Errorf("field %s = %#v, want %#v", k, got[k], want)
+				}
+			}
+			if _, ok := got["latency"].(time.Duration); !ok {
+				t.Errorf("field latency = %#v, want a time.Duration", got["latency"])
+			}
+		})
+	}
+}
+
+func TestZapLogger_Level(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	logger := NewZapLogger(zap.New(core))
+	logger.CtxDebugf(context.Background(), "debug %d", 1)
+	logger.CtxWarnf(context.Background(), "warn %d", 2)
+	logger.Fatal("fatal")
+	if got := logs.Len(); got != 2 {
+		t.Fatalf("got %d entries, want 2: %v", got, logs.All())
+	}
+	if e := logs.All()[1]; e.Level != zapcore.ErrorLevel || e.Message != "fatal" {
+		t.Errorf("Fatal logged %v %q, want error level without exiting", e.Level, e.Message)
+	}
+}
+
+func TestWithZapLogger_Events(t *testing.T) {
+	srv := newTestGateway(t, func(form map[string]string) interface{} {
+		switch form[APINameField] {
+		case queryPrinterStatus:
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": "离线。"}
+		case delPrinterSqs:
+			return map[string]interface{}{"ret": 1002, "msg": "打印机编号错误"}
+		default:
+			return map[string]interface{}{"ret": 0, "msg": "ok", "data": "sn_20240101120000_1"}
+		}
+	})
+	var (
+		ctx       = context.Background()
+		core, obs = observer.New(zapcore.InfoLevel)
+		c         = New(ctx, WithUser("user"), WithUserKey("ukey"), WithGateway(srv.URL), WithZapLogger(zap.New(core)),
+			WithIdempotencyStore(NewMemoryIdempotencyStore()))
+	)
+	for i := 0; i < 2; i++ {
+		if _, err := c.OpenPrintMsg(ctx, &PrintMsgReq{SN: "sn", Content: "test", IdempotencyKey: "order-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _ = NewFailover(c, WithFailoverClearQueue(true)).PrintMsg(ctx, PrinterGroup{Primary: "sn"}, &PrintMsgReq{Content: "test"})
+
+	tests := []struct {
+		msg    string
+		fields map[string]interface{}
+	}{
+		{"feie idempotency key already printed", map[string]interface{}{"order_id": "sn_20240101120000_1"}},
+		{"feie failover clear printer queue failed", map[string]interface{}{"sn": "sn"}},
+	}
+	for _, tt := range tests {
+		entries := obs.FilterMessage(tt.msg).All()
+		if len(entries) != 1 {
+			t.Fatalf("got %d %q entries, want 1: %v", len(entries), tt.msg, obs.All())
+		}
+		got := entries[0].ContextMap()
+		for k, want := range tt.fields {
+			if got[k] != want {
+				t.Errorf("%s field %s = %#v, want %#v", tt.msg, k, got[k], want)
+			}
+		}
+	}
+	if got := obs.FilterMessage("feie failover clear printer queue failed").All()[0].ContextMap(); got["error"] == nil {
+		t.Errorf("failover clear entry has no error field: %v", got)
+	}
+}